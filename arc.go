@@ -2,6 +2,7 @@ package gorsy_cache
 
 import (
 	"container/list"
+	"io"
 	"time"
 )
 
@@ -32,6 +33,7 @@ func (c *arcCache) getBaseCache() *baseCache {
 }
 
 func (c *arcCache) Init() {
+	c.resetItems()
 	c.part = c.size / 2
 	c.items = make(map[interface{}]*arcItem, c.size)
 	c.t1 = newArcList()
@@ -40,7 +42,13 @@ func (c *arcCache) Init() {
 	c.b2 = newArcList()
 }
 
+// Replace moves the lru item of t1 or t2 to its ghost list to make room for key. Unlike in the paper, the store
+// may be less than full after the items were removed, and nothing is replaced then.
 func (c *arcCache) Replace(key interface{}) {
+	if c.t1.Len()+c.t2.Len() < c.capacity() {
+		return
+	}
+
 	var old *arcItem
 	if c.t1.Len() != 0 && ((c.b2.Has(key) && c.t1.Len() == c.part) || (c.t1.Len() > c.part)) {
 		old = c.t1.Pop()
@@ -52,107 +60,164 @@ func (c *arcCache) Replace(key interface{}) {
 
 	if old != nil {
 		delete(c.items, old.key)
+		c.forget(&old.baseItem)
+		c.evicted(old.key, old.value, EvictionCapacity)
+		// only the key of a ghost is needed, the value is released
+		old.value = nil
 	}
 }
 
 func (c *arcCache) Get(key interface{}) (interface{}, error) {
 	c.Lock()
-	defer c.Unlock()
+	v, err := c.get(key)
+	c.Unlock()
+	if err == nil {
+		c.stats.hit()
+		return v, nil
+	}
 
-	return c.get(key, true)
+	c.stats.miss()
+	return c.load(key, c.get, c.Set)
 }
 
 func (c *arcCache) GetOnlyPresent(key interface{}) (interface{}, bool) {
 	c.Lock()
 	defer c.Unlock()
 
-	v, err := c.get(key, false)
+	v, err := c.get(key)
 	if err != nil {
+		c.stats.miss()
 		return nil, false
 	} else {
+		c.stats.hit()
 		return v, true
 	}
 }
 
-func (c *arcCache) get(key interface{}, fromLoader bool) (interface{}, error) {
+func (c *arcCache) get(key interface{}) (interface{}, error) {
 	item, ok := c.items[key]
-	if ok && !item.isExpired() {
-		if c.t1.Has(key) {
-			c.t1.MoveFront(key)
-		} else {
-			c.t2.MoveFront(key)
-		}
+	if ok && !item.isExpired(c.now()) {
+		c.hit(item)
 		return item.value, nil
 	}
 
-	if fromLoader {
-		return c.getFromLoader(key)
+	return nil, &KeyNotFoundError{c.Name, key, nil}
+}
+
+// hit moves a item requested again to the mru end of t2.
+func (c *arcCache) hit(item *arcItem) {
+	if c.t1.Remove(item.key) {
+		c.t2.Push(item)
 	} else {
-		return nil, &KeyNotFoundError{}
+		c.t2.MoveFront(item.key)
 	}
 }
 
 func (c *arcCache) Set(key, value interface{}) {
 	c.Lock()
-	defer c.Unlock()
+	defer c.unlockAndNotify()
 
 	c.set(key, value, DefaultExpiration)
 }
 
 func (c *arcCache) set(k, v interface{}, e time.Duration) {
+	w := c.weigh(k, v)
+	if c.tooHeavy(w) {
+		c.remove(k, EvictionCapacity)
+		c.reject(k, v)
+		return
+	}
+
 	item, ok := c.items[k]
 	if ok {
+		old := item.value
 		item.value = v
 		item.setExpiration(e, &c.baseCache)
+		c.setWeight(&item.baseItem, w)
+		c.evicted(k, old, EvictionReplaced)
+		c.hit(item)
+		c.evictOver(0, k)
 		return
 	}
 
-	item = &arcItem{baseItem{k, v, nil}}
+	c.evictOver(w, nil)
+	item = &arcItem{newBaseItem(k, v)}
 	item.setExpiration(e, &c.baseCache)
+	c.setWeight(&item.baseItem, w)
 	c.items[k] = item
 
-	if c.b1.Has(k) {
-		c.part = min(c.size, c.part+max(
-			c.b2.Len()/c.b1.Len(), 1,
-		))
+	// the cases of a miss in the paper of Megiddo and Modha.
+	size := c.capacity()
+	switch {
+	case c.b1.Has(k):
+		// a ghost hit in b1 favours the recency, raising the target size of t1.
+		c.part = min(size, c.part+max(c.b2.Len()/c.b1.Len(), 1))
 		c.Replace(k)
 		c.b1.Remove(k)
 		c.t2.Push(item)
-		c.t2.MoveFront(item.key)
-		return
-	} else if c.b2.Has(k) {
-		c.part = min(c.size, c.part-max(
-			c.b2.Len()/c.b1.Len(), 1,
-		))
+	case c.b2.Has(k):
+		// a ghost hit in b2 favours the frequency, lowering the target size of t1.
+		c.part = max(0, c.part-max(c.b1.Len()/c.b2.Len(), 1))
 		c.Replace(k)
 		c.b2.Remove(k)
 		c.t2.Push(item)
-		c.t2.MoveFront(item)
-		return
-	} else if c.t1.Len()+c.b1.Len() == c.size {
-		if c.t1.Len() < c.size {
-			c.b1.Pop()
+	default:
+		if c.t1.Len()+c.b1.Len() >= size {
+			if c.t1.Len() < size {
+				c.b1.Pop()
+				c.Replace(k)
+			} else {
+				e := c.t1.Pop()
+				delete(c.items, e.key)
+				c.forget(&e.baseItem)
+				c.evicted(e.key, e.value, EvictionCapacity)
+			}
+		} else if total := c.t1.Len() + c.b1.Len() + c.t2.Len() + c.b2.Len(); total >= size {
+			if total >= 2*size {
+				c.b2.Pop()
+			}
 			c.Replace(k)
-		} else {
-			e := c.t1.Pop()
-			delete(c.items, e.key)
 		}
-	} else {
-		total := c.t1.Len() + c.b1.Len() + c.t2.Len() + c.b2.Len()
-		if total >= (2 * c.size) {
-			c.b2.Pop()
+		c.t1.Push(item)
+	}
+}
+
+// evictOver evicts the items over the capacity, and makes room for extra more weight, moving the lru items
+// of t1 or t2 but keep to the ghost lists in the way of Replace.
+func (c *arcCache) evictOver(extra int64, keep interface{}) {
+	for len(c.items) > c.capacity() || c.overWeight(extra) {
+		from, ghost := c.t2, c.b2
+		if c.t1.Len() > 0 && (c.t1.Len() > c.part || c.t2.Len() == 0) {
+			from, ghost = c.t1, c.b1
+		}
+		old := from.Pop()
+		if old != nil && old.key == keep {
+			from.Push(old)
+			if from == c.t1 {
+				from, ghost = c.t2, c.b2
+			} else {
+				from, ghost = c.t1, c.b1
+			}
+			old = from.Pop()
+		}
+		if old == nil {
+			return
 		}
 
-		c.Replace(k)
+		ghost.Push(old)
+		if ghost.Len() > c.capacity() {
+			ghost.Pop()
+		}
+		delete(c.items, old.key)
+		c.forget(&old.baseItem)
+		c.evicted(old.key, old.value, EvictionCapacity)
+		old.value = nil
 	}
-
-	c.t1.Push(item)
-	c.t1.MoveFront(item.key)
 }
 
 func (c *arcCache) SetWithExpire(k, v interface{}, e time.Duration) {
 	c.Lock()
-	defer c.Unlock()
+	defer c.unlockAndNotify()
 
 	c.set(k, v, e)
 }
@@ -162,30 +227,40 @@ func (c *arcCache) Has(key interface{}) bool {
 	defer c.RUnlock()
 
 	item, ok := c.items[key]
-	return ok && !item.isExpired()
+	return ok && !item.isExpired(c.now())
+}
+
+func (c *arcCache) TTL(key interface{}) (time.Duration, bool) {
+	c.RLock()
+	defer c.RUnlock()
+
+	now := c.now()
+	item, ok := c.items[key]
+	if !ok || item.isExpired(now) {
+		return 0, false
+	}
+	return item.ttl(now), true
 }
 
 func (c *arcCache) Remove(key interface{}) bool {
 	c.Lock()
-	defer c.Unlock()
+	defer c.unlockAndNotify()
 
-	return c.remove(key)
+	return c.remove(key, EvictionExplicit)
 }
 
-func (c *arcCache) remove(key interface{}) bool {
+func (c *arcCache) remove(key interface{}, reason EvictionReason) bool {
 	item, ok := c.items[key]
 	if !ok {
 		return false
 	}
 	delete(c.items, key)
-	c.t1.Remove(item)
-	c.t2.Remove(item)
+	c.forget(&item.baseItem)
+	c.t1.Remove(key)
+	c.t2.Remove(key)
+	c.evicted(key, item.value, reason)
 
-	if c.BeforeEvictedFunc != nil {
-		c.BeforeEvictedFunc(key, item.value)
-	}
-
-	return !item.isExpired()
+	return !item.isExpired(c.now())
 }
 
 func (c *arcCache) Keys() []interface{} {
@@ -193,8 +268,9 @@ func (c *arcCache) Keys() []interface{} {
 	defer c.RUnlock()
 
 	keys := make([]interface{}, 0)
+	now := c.now()
 	for k, v := range c.items {
-		if !v.isExpired() {
+		if !v.isExpired(now) {
 			keys = append(keys, k)
 		}
 	}
@@ -203,45 +279,128 @@ func (c *arcCache) Keys() []interface{} {
 }
 
 func (c *arcCache) CleanExpired() int {
+	return c.cleanExpired(func(key interface{}) {
+		c.remove(key, EvictionExpired)
+	})
+}
+
+func (c *arcCache) Flush() {
 	c.Lock()
-	defer c.Unlock()
+	defer c.unlockAndNotify()
 
-	expiredKey := make([]interface{}, 0)
-	for k, v := range c.items {
-		if v.isExpired() {
-			expiredKey = append(expiredKey, k)
-		}
+	for k, item := range c.items {
+		c.evicted(k, item.value, EvictionFlushed)
 	}
+	c.Init()
+}
+
+func (c *arcCache) drop() {
+	c.Lock()
+	defer c.Unlock()
+
+	c.stats.evicted(EvictionFlushed, len(c.items))
+	c.Init()
+}
 
-	for _, k := range expiredKey {
-		c.remove(k)
+// Resize changes the size of the store. The target size of t1 is scaled with the size, and the ghost lists
+// are trimmed to the new size, so the adaption goes on from the history kept.
+func (c *arcCache) Resize(n int) {
+	if n < 1 {
+		return
 	}
+	c.Lock()
+	defer c.unlockAndNotify()
 
-	return len(expiredKey)
+	if c.size > 0 {
+		c.part = c.part * n / c.size
+	}
+	c.size = n
+	c.trim()
 }
 
-func (c *arcCache) Flush() {
+func (c *arcCache) setLimit(n int) {
 	c.Lock()
-	defer c.Unlock()
+	defer c.unlockAndNotify()
 
-	c.Init()
+	c.limit = n
+	c.trim()
+}
+
+// trim evicts the items over the capacity or the max weight, and the ghosts over the capacity.
+func (c *arcCache) trim() {
+	c.part = min(c.part, c.capacity())
+	c.evictOver(0, nil)
+	for c.b1.Len()+c.b2.Len() > c.capacity() {
+		if c.b1.Len() > c.b2.Len() {
+			c.b1.Pop()
+		} else {
+			c.b2.Pop()
+		}
+	}
 }
 
 func (c *arcCache) Len() int {
+	c.RLock()
+	defer c.RUnlock()
+
 	return len(c.items)
 }
 
-func (c *arcCache) getFromLoader(key interface{}) (interface{}, error) {
-	var v interface{}
-	var err error
-	if c.LoaderFunc != nil {
-		v, err = c.LoaderFunc(key)
-		if err != nil {
-			return nil, &KeyNotFoundError{c.Name, key, err}
+func (c *arcCache) Stats() Stats {
+	c.RLock()
+	defer c.RUnlock()
+
+	return c.snapshotStats(len(c.items))
+}
+
+func (c *arcCache) SaveTo(w io.Writer) error {
+	return saveTo(c, w)
+}
+
+func (c *arcCache) LoadFrom(r io.Reader) error {
+	return loadFrom(c, r)
+}
+
+func (c *arcCache) snapshot() *snapshot {
+	c.RLock()
+	defer c.RUnlock()
+
+	s := &snapshot{Policy: c.policy, Part: c.part, Entries: make([]snapshotEntry, 0, len(c.items))}
+	now := c.now()
+	for _, seg := range []struct {
+		id int
+		l  *arcList
+	}{{segmentT1, c.t1}, {segmentT2, c.t2}} {
+		for e := seg.l.l.Back(); e != nil; e = e.Prev() {
+			item := e.Value.(*arcItem)
+			if !item.isExpired(now) {
+				entry := newSnapshotEntry(&item.baseItem)
+				entry.Segment = seg.id
+				s.Entries = append(s.Entries, entry)
+			}
 		}
 	}
+	return s
+}
 
-	return v, &KeyNotFoundError{c.Name, key, nil}
+func (c *arcCache) restore(s *snapshot) {
+	c.Lock()
+	defer c.unlockAndNotify()
+
+	for _, e := range s.Entries {
+		c.set(e.Key, e.Value, NoExpiration)
+		item, ok := c.items[e.Key]
+		if !ok {
+			continue
+		}
+		item.expireAt(e.Expiration, &c.baseCache)
+		if (e.Segment == segmentT2 || (e.Segment == 0 && e.Freq > 0)) && c.t1.Remove(e.Key) {
+			c.t2.Push(item)
+		}
+	}
+	if s.Policy == c.policy {
+		c.part = min(s.Part, c.size)
+	}
 }
 
 type arcItem struct {
@@ -1,25 +1,56 @@
 package gorsy_cache
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"reflect"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// The expirations and the purge interval are real durations, such as 5*time.Minute.
 const (
-	NoExpiration      = -1
-	DefaultExpiration = 0
+	// NoExpiration makes a item never expire.
+	NoExpiration time.Duration = -1
+	// DefaultExpiration makes a item expire after the default expiration of the cache.
+	DefaultExpiration time.Duration = 0
 
-	NoPurge              = -1
-	DefaultPurgeInterval = 0
+	// NoPurge disables the periodic collection of the expired items.
+	NoPurge time.Duration = -1
+	// DefaultPurgeInterval collects the expired items every defaultPurgeInterval.
+	DefaultPurgeInterval time.Duration = 0
+
+	defaultExpiration    = time.Minute
+	defaultPurgeInterval = time.Minute
+)
+
+// The purge modes pick how the expired items are found.
+const (
+	// PurgeIndexed keeps the items with a expiration ordered by the expiration, so the purging collects
+	// exactly the expired items at the cost of a heap update on every set.
+	PurgeIndexed PurgeMode = iota
+	// PurgeSampled works like the active expiration of Redis: the purging checks a few random items with a
+	// expiration, and goes on while many of them were expired. The sets only pay a append.
+	PurgeSampled
+
+	defaultPurgeSamples    = 20
+	defaultPurgeSampleRate = 0.25
 )
 
+// PurgeMode is the way a cache store finds its expired items, see SetPurgeMode.
+type PurgeMode int
+
 // cacheCollected collects the specific cache object constructor.
-var cacheCollected = make(map[string]func() interface{})
+// The built-in caches are registered by the init functions, the others by RegisterPolicy.
+var (
+	cacheCollected   = make(map[string]func() interface{})
+	cacheCollectedMu sync.RWMutex
+)
 
-// cacheCounter distinguish anonymous cache store
-var cacheCounter int
+// cacheCounter distinguish anonymous cache store, it is accessed atomically.
+var cacheCounter int64
 
 // Cache represents a interface used by the user to r/w the cache store.
 type Cache interface {
@@ -31,41 +62,188 @@ type Cache interface {
 	Set(key, value interface{})
 	SetWithExpire(key, value interface{}, duration time.Duration)
 	Has(key interface{}) bool
+	// TTL returns the remaining time to live of a present key, NoExpiration if it never expires.
+	TTL(key interface{}) (time.Duration, bool)
 	Remove(key interface{}) bool
 	Keys() []interface{}
 	CleanExpired() int
 	Flush()
 	Len() int
+	// Resize changes the size of the store, the items over the new size are evicted by the policy.
+	// A size less than 1, or than the number of the shards of a sharded cache, is ignored.
+	Resize(n int)
+	Stats() Stats
+	ResetStats()
+	// Close stops the purging of the store and releases it, the later operations return ErrClosed or do nothing.
+	Close() error
+	// Migrate swaps the store for one of another registered policy, carrying the entries over.
+	Migrate(to string) error
+	SaveTo(w io.Writer) error
+	LoadFrom(r io.Reader) error
+
+	// drop releases all of the items like Flush, without calling the callbacks.
+	drop()
+	// setLimit lowers the capacity of the store to n items, evicting the items over it, 0 lifts the limit.
+	setLimit(n int)
+	// snapshot returns the live entries of the store, restore stores the entries of a snapshot.
+	snapshot() *snapshot
+	restore(s *snapshot)
+	// setRetired makes the store refuse the later changes, or accept them again, see baseCache.Lock.
+	setRetired(retired bool)
 }
 
 // baseCache provides a set of common attributes. A specific cache implementation is required to inherit it.
 type baseCache struct {
 	sync.RWMutex
 	size int
-	Name string
+	// policy is the registered name of the cache implementation.
+	policy string
+	Name   string
 	// expiration provides a global expiration information for a cache store.
 	Expiration time.Duration
 	// PurgeInterval specifies the expired record collection interval.
 	PurgeInterval time.Duration
+	// legacySeconds makes the expirations count in seconds, see LegacySeconds.
+	legacySeconds bool
+	// evictOnClose makes Close call the callbacks for the items left.
+	evictOnClose bool
 	LoaderFunc
 	BeforeEvictedFunc
+	EvictedFunc
+	EvictionPanicFunc
+	// delivery picks when the callbacks are called, the notifications not delivered yet are pending
+	// until the lock is released, and handed to the workers under DeliverAsync.
+	delivery EvictionDelivery
+	pending  []eviction
+	workers  *evictionWorkers
+	// owner is the Cache returned by Build, which the loaded values are stored through.
+	owner *handle
+	// retired is set by Migrate under the lock once the store is being replaced, see Lock.
+	retired bool
+	// codec encodes and decodes the snapshots of the store.
+	codec Codec
+	// clock tells the time for the expiration and the purging, the system clock if it is nil.
+	clock Clock
+
+	// purgeBatch bounds the number of the items collected under the lock at a time, 0 means unbounded.
+	purgeBatch int
+	purgeMode  PurgeMode
+	// purgeSamples is the number of the items checked per round of the sampled purging, which goes on
+	// while more than purgeSampleRate of a round were expired.
+	purgeSamples    int
+	purgeSampleRate float64
+	expirations     expirationIndex
+
+	// limit lowers the number of the items below size while it is positive, see Governor.
+	limit int
+
+	// weight is the total weight of the items, bounded by maxWeight if it is positive.
+	weigher   Weigher
+	maxWeight int64
+	weight    int64
+	// memoryWeigher is set if the weigher is the MemoryWeigher of the policy, rebuilt by Migrate for the new one.
+	memoryWeigher bool
+
+	loads loadGroup
+	stats cacheStats
+}
+
+// errRetired is the panic of Lock on a retired store, recovered by the handle, see handle.do.
+var errRetired = errors.New("cache store has been retired")
+
+// Lock takes the write lock of the store. A store retired by Migrate is never changed again, so Lock panics
+// with errRetired instead, and the handle runs the operation again on the new store.
+// Every change of a store starts by Lock, before anything has been changed.
+func (c *baseCache) Lock() {
+	c.RWMutex.Lock()
+	if c.retired {
+		c.RWMutex.Unlock()
+		panic(errRetired)
+	}
+}
+
+// setRetired waits for the operations holding the lock, and makes the later ones panic in Lock if retired is set.
+// A migration failing after the store was retired clears it, so that the store is used again.
+func (c *baseCache) setRetired(retired bool) {
+	c.RWMutex.Lock()
+	defer c.RWMutex.Unlock()
+
+	c.retired = retired
+}
+
+// Close of a store is a no-op, the lifecycle of a store is owned by the Cache returned by Build.
+func (c *baseCache) Close() error {
+	return nil
 }
 
 type (
-	LoaderFunc func(key interface{}) (interface{}, error)
+	LoaderFunc        func(key interface{}) (interface{}, error)
 	BeforeEvictedFunc func(key, value interface{})
+	// EvictedFunc is called for every item leaving the store, and for every value overwritten by a Set.
+	EvictedFunc func(key, value interface{}, reason EvictionReason)
 )
 
+// evicted records a item leaving the store and notifies the callbacks, it must be called with the lock held.
+// Unless the delivery is DeliverLocked, the notification is pending until unlockAndNotify.
+func (c *baseCache) evicted(key, value interface{}, reason EvictionReason) {
+	c.stats.evicted(reason, 1)
+	if c.BeforeEvictedFunc == nil && c.EvictedFunc == nil {
+		return
+	}
+
+	e := eviction{key, value, reason}
+	if c.delivery == DeliverLocked {
+		c.notify(e)
+		return
+	}
+	c.pending = append(c.pending, e)
+}
+
+// inherit copies the setups of o, which is used to build several caches from one builder.
+func (c *baseCache) inherit(o *baseCache) {
+	c.size = o.size
+	c.policy = o.policy
+	c.Name = o.Name
+	c.Expiration = o.Expiration
+	c.PurgeInterval = o.PurgeInterval
+	c.legacySeconds = o.legacySeconds
+	c.evictOnClose = o.evictOnClose
+	c.purgeBatch = o.purgeBatch
+	c.purgeMode = o.purgeMode
+	c.purgeSamples = o.purgeSamples
+	c.purgeSampleRate = o.purgeSampleRate
+	c.LoaderFunc = o.LoaderFunc
+	c.BeforeEvictedFunc = o.BeforeEvictedFunc
+	c.EvictedFunc = o.EvictedFunc
+	c.EvictionPanicFunc = o.EvictionPanicFunc
+	c.delivery = o.delivery
+	c.workers = o.workers
+	c.owner = o.owner
+	c.codec = o.codec
+	c.clock = o.clock
+	c.weigher = o.weigher
+	c.memoryWeigher = o.memoryWeigher
+	c.maxWeight = o.maxWeight
+}
+
 // cacheBuilder used to build a specific cache.
 type cacheBuilder struct {
 	cache Cache
 	bc    *baseCache
+	// constructor and shards are used to build a sharded cache.
+	constructor func() interface{}
+	shards      int
+	// workers, queue and overflow set up the workers of DeliverAsync.
+	workers, queue int
+	overflow       OverflowPolicy
 }
 
 // NewBuilder receive a constant cache name and a cache size, return a specific cache builder.
 // A error will be returned if the specific cache is not registered or the cache implementation is invalid.
 func NewBuilder(name string, size int) (*cacheBuilder, error) {
+	cacheCollectedMu.RLock()
 	f, ok := cacheCollected[name]
+	cacheCollectedMu.RUnlock()
 	if !ok {
 		return nil, fmt.Errorf("no specific cache was found")
 	}
@@ -75,39 +253,76 @@ func NewBuilder(name string, size int) (*cacheBuilder, error) {
 		return nil, fmt.Errorf("specific cache invalid: %s", err.Error())
 	}
 
-	builder := &cacheBuilder{cache: c.(Cache)}
+	builder := &cacheBuilder{cache: c.(Cache), constructor: f, shards: 1}
 	builder.bc = builder.cache.getBaseCache()
 	builder.bc.size = size
+	builder.bc.policy = name
 	return builder, nil
 }
 
 // Build a cache store by the previous setups.
 // Typically it will perform some tasks such as allocating cache space.
+// A cache store purging the expired items is held by the purging until it is closed, so it must be closed
+// once no longer used, or it is never collected.
 func (c *cacheBuilder) Build() Cache {
 	if c.bc.Name == "" {
-		c.bc.Name = fmt.Sprintf("cache: %d", cacheCounter)
-		cacheCounter++
+		c.bc.Name = fmt.Sprintf("cache: %d", atomic.AddInt64(&cacheCounter, 1)-1)
+	}
+	if c.bc.legacySeconds {
+		c.bc.Expiration = c.bc.legacyDuration(c.bc.Expiration)
+		c.bc.PurgeInterval = c.bc.legacyDuration(c.bc.PurgeInterval)
 	}
+	c.bc.Expiration = bareSeconds("default expiration", c.bc.Expiration)
+	c.bc.PurgeInterval = bareSeconds("purge interval", c.bc.PurgeInterval)
 	if c.bc.Expiration == DefaultExpiration {
-		c.bc.Expiration = 60
+		c.bc.Expiration = defaultExpiration
 	}
 	if c.bc.PurgeInterval == DefaultPurgeInterval {
-		c.bc.PurgeInterval = 60
+		c.bc.PurgeInterval = defaultPurgeInterval
+	}
+	if c.bc.purgeSamples <= 0 {
+		c.bc.purgeSamples = defaultPurgeSamples
+	}
+	if c.bc.purgeSampleRate <= 0 {
+		c.bc.purgeSampleRate = defaultPurgeSampleRate
 	}
 
-	c.cache.Init()
+	if c.bc.delivery == DeliverAsync {
+		if c.workers <= 0 {
+			c.workers = defaultEvictionWorkers
+		}
+		if c.queue <= 0 {
+			c.queue = defaultEvictionQueue
+		}
+		c.bc.workers = newEvictionWorkers(c.workers, c.queue, c.overflow)
+	}
 
+	h := &handle{}
+	c.bc.owner = h
+	h.c.Store(newStoreRef(c.store()))
 	if c.bc.PurgeInterval != NoPurge {
-		_ = StartPurge(&c.cache, c.bc.PurgeInterval)
+		_ = startPurge(h, c.bc.PurgeInterval)
+	}
+	return h
+}
+
+// store allocates the cache store by the setups, split into shards if there are several.
+func (c *cacheBuilder) store() Cache {
+	if c.shards > 1 {
+		c.cache = c.buildShards()
 	}
+	c.cache.Init()
 	return c.cache
 }
 
+// SetName names the cache store in its errors and statistics, and labels its series in the metrics package.
 func (c *cacheBuilder) SetName(n string) *cacheBuilder {
 	c.bc.Name = n
 	return c
 }
 
+// SetDefaultExpiration sets the expiration of the items set without one, a minute by default. A expiration
+// under a millisecond is taken by Build as a bare number of seconds of the earlier versions, and logged.
 func (c *cacheBuilder) SetDefaultExpiration(t time.Duration) *cacheBuilder {
 	c.bc.Expiration = t
 	return c
@@ -123,11 +338,120 @@ func (c *cacheBuilder) SetBeforeEvictedFunc(f BeforeEvictedFunc) *cacheBuilder {
 	return c
 }
 
+// SetEvictedFunc sets the callback called for every item leaving the store with the reason of it,
+// including the capacity evictions, the expirations, Remove, Flush and the values overwritten by Set.
+func (c *cacheBuilder) SetEvictedFunc(f EvictedFunc) *cacheBuilder {
+	c.bc.EvictedFunc = f
+	return c
+}
+
+// SetEvictionDelivery picks when the eviction callbacks are called. By default they are called with the cache
+// lock held, DeliverAfterUnlock and DeliverAsync let them use the same cache and keep the other operations going.
+func (c *cacheBuilder) SetEvictionDelivery(d EvictionDelivery) *cacheBuilder {
+	c.bc.delivery = d
+	return c
+}
+
+// SetEvictionWorkers sets up the workers of DeliverAsync: the number of goroutines calling the callbacks,
+// the size of the queue in front of them, and what to do when the queue is full. The defaults are
+// 1 worker, which keeps the order of the notifications, a queue of 1024 and OverflowBlock.
+func (c *cacheBuilder) SetEvictionWorkers(workers, queue int, overflow OverflowPolicy) *cacheBuilder {
+	c.workers = workers
+	c.queue = queue
+	c.overflow = overflow
+	return c
+}
+
+// SetEvictionPanicFunc sets the function reporting the panics recovered from the eviction callbacks,
+// which are logged by default.
+func (c *cacheBuilder) SetEvictionPanicFunc(f EvictionPanicFunc) *cacheBuilder {
+	c.bc.EvictionPanicFunc = f
+	return c
+}
+
+// SetPurgeInterval sets the interval of the collection of the expired items, a minute by default. A interval
+// under a millisecond is taken by Build as a bare number of seconds of the earlier versions, and logged.
 func (c *cacheBuilder) SetPurgeInterval(t time.Duration) *cacheBuilder {
 	c.bc.PurgeInterval = t
 	return c
 }
 
+// SetPurgeBatch makes the purging collect at most n expired items under the lock at a time,
+// releasing the lock between the batches so that the readers of a large store aren't blocked for long.
+func (c *cacheBuilder) SetPurgeBatch(n int) *cacheBuilder {
+	c.bc.purgeBatch = n
+	return c
+}
+
+// SetClock sets the clock telling the time for the expiration and the purging of the cache, mostly to control
+// the time in the tests. The system clock is used by default.
+func (c *cacheBuilder) SetClock(clock Clock) *cacheBuilder {
+	c.bc.clock = comparableClock(clock)
+	return c
+}
+
+// SetPurgeMode picks the way the purging finds the expired items, PurgeIndexed by default.
+// PurgeSampled suits the stores with few items with a expiration among many permanent ones.
+func (c *cacheBuilder) SetPurgeMode(m PurgeMode) *cacheBuilder {
+	c.bc.purgeMode = m
+	return c
+}
+
+// SetPurgeSampling tunes PurgeSampled: every round checks n random items with a expiration, and another round
+// follows while more than rate of them were expired. The defaults are 20 items and 0.25.
+func (c *cacheBuilder) SetPurgeSampling(n int, rate float64) *cacheBuilder {
+	c.bc.purgeSamples = n
+	c.bc.purgeSampleRate = rate
+	return c
+}
+
+// SetWeigher sets the function weighing the entries, such as the size of the values in bytes.
+// The store evicts until the total weight fits in the max weight set by SetMaxWeight.
+func (c *cacheBuilder) SetWeigher(f Weigher) *cacheBuilder {
+	c.bc.weigher = f
+	c.bc.memoryWeigher = false
+	return c
+}
+
+// SetMaxWeight bounds the total weight of the entries, 0 means unbounded. The size given to NewBuilder still
+// bounds the number of the entries. A entry heavier than the max weight is rejected by Set, and the callbacks are
+// called for it with EvictionCapacity.
+func (c *cacheBuilder) SetMaxWeight(w int64) *cacheBuilder {
+	c.bc.maxWeight = w
+	return c
+}
+
+// SetMaxMemory bounds the estimated bytes of heap taken by the entries, weighing them by MemoryWeigher.
+// It replaces the weigher and the max weight set before.
+func (c *cacheBuilder) SetMaxMemory(bytes int64) *cacheBuilder {
+	c.bc.weigher = MemoryWeigher(c.bc.policy)
+	c.bc.memoryWeigher = true
+	c.bc.maxWeight = bytes
+	return c
+}
+
+// SetEvictOnClose makes Close flush the store, calling the callbacks for every item left in it with EvictionFlushed.
+func (c *cacheBuilder) SetEvictOnClose(b bool) *cacheBuilder {
+	c.bc.evictOnClose = b
+	return c
+}
+
+// LegacySeconds makes the cache count its durations in seconds as the earlier versions did,
+// so that the expirations given to SetDefaultExpiration, SetPurgeInterval, SetWithExpire and StartPurge,
+// such as a bare 60, are multiplied by time.Second.
+//
+// Deprecated: pass real durations such as 60*time.Second instead. It only eases the migration of the call sites.
+func (c *cacheBuilder) LegacySeconds() *cacheBuilder {
+	c.bc.legacySeconds = true
+	return c
+}
+
+// SetCodec sets the codec of the snapshots saved by SaveTo and loaded by LoadFrom, GobCodec by default.
+func (c *cacheBuilder) SetCodec(codec Codec) *cacheBuilder {
+	c.bc.codec = codec
+	return c
+}
+
 func checkCacheValid(c interface{}) error {
 	if !implementedCache(c) {
 		return fmt.Errorf("cache has not implement the Cache interface")
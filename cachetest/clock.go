@@ -0,0 +1,18 @@
+// Package cachetest helps to test the code using gorsy_cache.
+package cachetest
+
+import (
+	"time"
+
+	"github.com/arianxx/gorsy-cache/internal/fakeclock"
+)
+
+// FakeClock is a gorsy_cache.Clock whose time only moves on by Advance, so the expiration is tested without
+// sleeping. The timers due are fired by Advance in its own goroutine in the order of their times, so the purging
+// scheduled by the clock has been done when Advance returns.
+type FakeClock = fakeclock.Clock
+
+// NewFakeClock returns a fake clock starting at now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return fakeclock.New(now)
+}
@@ -0,0 +1,407 @@
+package cachetest
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	gorsy_cache "github.com/arianxx/gorsy-cache"
+)
+
+// conformanceSize is the size of the caches checked by RunConformance.
+const conformanceSize = 64
+
+// RunConformance checks that a cache of the registered policy keeps the contract of gorsy_cache.Cache:
+// the capacity bound, the expiration, the agreement of Has and Get, Keys and Remove, the loader, the eviction
+// callbacks and the concurrent use, which is worth running with -race.
+// A custom policy is checked by registering it by gorsy_cache.RegisterPolicy before.
+func RunConformance(t *testing.T, policy string) {
+	t.Helper()
+
+	tests := []struct {
+		name string
+		f    func(t *testing.T, policy string)
+	}{
+		{"Capacity", testCapacity},
+		{"Fill", testFill},
+		{"SetGet", testSetGet},
+		{"Expiration", testExpiration},
+		{"HasGetAgree", testHasGetAgree},
+		{"Keys", testKeys},
+		{"Remove", testRemove},
+		{"Loader", testLoader},
+		{"LoaderSingleFlight", testLoaderSingleFlight},
+		{"EvictedFunc", testEvictedFunc},
+		{"Concurrent", testConcurrent},
+	}
+	for _, tt := range tests {
+		f := tt.f
+		t.Run(tt.name, func(t *testing.T) {
+			f(t, policy)
+		})
+	}
+}
+
+// setup holds the builder setups of a cache under test.
+type setup struct {
+	clock   gorsy_cache.Clock
+	loader  gorsy_cache.LoaderFunc
+	evicted gorsy_cache.EvictedFunc
+}
+
+// build returns a cache of the policy by the setup, closed when the test finishes.
+// The cache is never purged unless its clock is advanced.
+func build(t *testing.T, policy string, size int, s setup) gorsy_cache.Cache {
+	t.Helper()
+
+	b, err := gorsy_cache.NewBuilder(policy, size)
+	if err != nil {
+		t.Fatalf("NewBuilder(%q): %v", policy, err)
+	}
+	if s.clock == nil {
+		s.clock = NewFakeClock(time.Now())
+	}
+	b.SetClock(s.clock)
+	if s.loader != nil {
+		b.SetLoaderFunc(s.loader)
+	}
+	if s.evicted != nil {
+		b.SetEvictedFunc(s.evicted)
+	}
+	c := b.Build()
+	t.Cleanup(func() {
+		_ = c.Close()
+	})
+	return c
+}
+
+func testCapacity(t *testing.T, policy string) {
+	c := build(t, policy, conformanceSize, setup{})
+	for i := 0; i < 4*conformanceSize; i++ {
+		c.Set(i, i)
+		if n := c.Len(); n > conformanceSize {
+			t.Fatalf("Len() = %d after %d sets, over the size %d", n, i+1, conformanceSize)
+		}
+	}
+	if n := len(c.Keys()); n > conformanceSize {
+		t.Fatalf("len(Keys()) = %d, over the size %d", n, conformanceSize)
+	}
+	if n := c.Len(); n == 0 {
+		t.Fatalf("Len() = 0 after overfilling")
+	}
+}
+
+func testFill(t *testing.T, policy string) {
+	c := build(t, policy, conformanceSize, setup{})
+	for i := 0; i < conformanceSize; i++ {
+		c.Set(i, i)
+	}
+	if n := c.Len(); n != conformanceSize {
+		t.Fatalf("Len() = %d after filling, want %d", n, conformanceSize)
+	}
+	for i := 0; i < conformanceSize; i++ {
+		if !c.Has(i) {
+			t.Fatalf("key %d was evicted before the cache was full", i)
+		}
+	}
+}
+
+func testSetGet(t *testing.T, policy string) {
+	c := build(t, policy, conformanceSize, setup{})
+	c.Set("a", 1)
+	if v, err := c.Get("a"); err != nil || v != 1 {
+		t.Fatalf("Get(a) = %v, %v, want 1", v, err)
+	}
+	c.Set("a", 2)
+	if v, ok := c.GetOnlyPresent("a"); !ok || v != 2 {
+		t.Fatalf("GetOnlyPresent(a) = %v, %v after overwriting, want 2", v, ok)
+	}
+	if n := c.Len(); n != 1 {
+		t.Fatalf("Len() = %d after overwriting, want 1", n)
+	}
+
+	_, err := c.Get("missing")
+	var notFound *gorsy_cache.KeyNotFoundError
+	if !errors.As(err, &notFound) {
+		t.Fatalf("Get(missing) error = %v, want a KeyNotFoundError", err)
+	}
+	if _, ok := c.GetOnlyPresent("missing"); ok {
+		t.Fatalf("GetOnlyPresent(missing) found a value")
+	}
+}
+
+func testExpiration(t *testing.T, policy string) {
+	clock := NewFakeClock(time.Now())
+	c := build(t, policy, conformanceSize, setup{clock: clock})
+	c.SetWithExpire("short", 1, time.Minute)
+	c.SetWithExpire("long", 2, time.Hour)
+	c.SetWithExpire("forever", 3, gorsy_cache.NoExpiration)
+
+	if ttl, ok := c.TTL("short"); !ok || ttl != time.Minute {
+		t.Fatalf("TTL(short) = %v, %v, want %v", ttl, ok, time.Minute)
+	}
+	if ttl, ok := c.TTL("forever"); !ok || ttl != gorsy_cache.NoExpiration {
+		t.Fatalf("TTL(forever) = %v, %v, want NoExpiration", ttl, ok)
+	}
+	if _, ok := c.TTL("missing"); ok {
+		t.Fatalf("TTL(missing) found a ttl")
+	}
+
+	clock.Advance(30 * time.Second)
+	if ttl, ok := c.TTL("short"); !ok || ttl != 30*time.Second {
+		t.Fatalf("TTL(short) = %v, %v after 30s, want 30s", ttl, ok)
+	}
+
+	clock.Advance(31 * time.Second)
+	if c.Has("short") {
+		t.Fatalf("Has(short) after it expired")
+	}
+	if _, err := c.Get("short"); err == nil {
+		t.Fatalf("Get(short) found a value after it expired")
+	}
+	if _, ok := c.TTL("short"); ok {
+		t.Fatalf("TTL(short) found a ttl after it expired")
+	}
+	if !c.Has("long") || !c.Has("forever") {
+		t.Fatalf("the entries not expired yet are missing")
+	}
+
+	c.SetWithExpire("short", 4, time.Minute)
+	if v, err := c.Get("short"); err != nil || v != 4 {
+		t.Fatalf("Get(short) = %v, %v after setting again, want 4", v, err)
+	}
+
+	clock.Advance(2 * time.Hour)
+	c.CleanExpired()
+	if n := c.Len(); n != 1 {
+		t.Fatalf("Len() = %d after cleaning the expired entries, want 1", n)
+	}
+}
+
+func testHasGetAgree(t *testing.T, policy string) {
+	clock := NewFakeClock(time.Now())
+	c := build(t, policy, conformanceSize, setup{clock: clock})
+	c.Set("present", 1)
+	c.SetWithExpire("expired", 2, time.Second)
+	c.Set("removed", 3)
+	c.Remove("removed")
+	clock.Advance(time.Minute)
+
+	for _, k := range []string{"present", "expired", "removed", "missing"} {
+		has := c.Has(k)
+		_, ok := c.GetOnlyPresent(k)
+		_, err := c.Get(k)
+		if has != ok || has != (err == nil) {
+			t.Fatalf("%s: Has() = %v, GetOnlyPresent() found %v, Get() error %v", k, has, ok, err)
+		}
+		if want := k == "present"; has != want {
+			t.Fatalf("Has(%s) = %v, want %v", k, has, want)
+		}
+	}
+}
+
+func testKeys(t *testing.T, policy string) {
+	clock := NewFakeClock(time.Now())
+	c := build(t, policy, conformanceSize, setup{clock: clock})
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.SetWithExpire("c", 3, time.Second)
+	clock.Advance(time.Minute)
+
+	keys := make([]string, 0)
+	for _, k := range c.Keys() {
+		keys = append(keys, k.(string))
+	}
+	sort.Strings(keys)
+	if fmt.Sprint(keys) != "[a b]" {
+		t.Fatalf("Keys() = %v, want [a b]", keys)
+	}
+}
+
+func testRemove(t *testing.T, policy string) {
+	clock := NewFakeClock(time.Now())
+	c := build(t, policy, conformanceSize, setup{clock: clock})
+	c.Set("a", 1)
+	c.SetWithExpire("expired", 2, time.Second)
+	clock.Advance(time.Minute)
+
+	if !c.Remove("a") {
+		t.Fatalf("Remove(a) = false for a present key")
+	}
+	if c.Remove("a") {
+		t.Fatalf("Remove(a) = true for a removed key")
+	}
+	if c.Remove("missing") {
+		t.Fatalf("Remove(missing) = true")
+	}
+	if c.Remove("expired") {
+		t.Fatalf("Remove(expired) = true for a expired key")
+	}
+	if n := c.Len(); n != 0 {
+		t.Fatalf("Len() = %d after removing all, want 0", n)
+	}
+}
+
+func testLoader(t *testing.T, policy string) {
+	errLoad := errors.New("load failed")
+	var calls int32
+	c := build(t, policy, conformanceSize, setup{loader: func(key interface{}) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		if key == "bad" {
+			return nil, errLoad
+		}
+		return fmt.Sprint("v", key), nil
+	}})
+
+	if v, err := c.Get("a"); err != nil || v != "va" {
+		t.Fatalf("Get(a) = %v, %v, want the loaded va", v, err)
+	}
+	if !c.Has("a") {
+		t.Fatalf("the loaded value was not stored")
+	}
+	if v, err := c.Get("a"); err != nil || v != "va" || atomic.LoadInt32(&calls) != 1 {
+		t.Fatalf("Get(a) = %v, %v after loading, the loader called %d times", v, err, calls)
+	}
+
+	if _, err := c.Get("bad"); !errors.Is(err, errLoad) {
+		t.Fatalf("Get(bad) error = %v, want the loader error", err)
+	}
+	if c.Has("bad") {
+		t.Fatalf("a failed load was stored")
+	}
+	if _, ok := c.GetOnlyPresent("other"); ok || atomic.LoadInt32(&calls) != 2 {
+		t.Fatalf("GetOnlyPresent called the loader")
+	}
+}
+
+func testLoaderSingleFlight(t *testing.T, policy string) {
+	var calls int32
+	release := make(chan struct{})
+	c := build(t, policy, conformanceSize, setup{loader: func(key interface{}) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return 1, nil
+	}})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := c.Get("k"); err != nil || v != 1 {
+				t.Errorf("Get(k) = %v, %v, want 1", v, err)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("the loader was called %d times for concurrent misses of a key, want 1", n)
+	}
+}
+
+func testEvictedFunc(t *testing.T, policy string) {
+	var mu sync.Mutex
+	reasons := make(map[gorsy_cache.EvictionReason]int)
+	clock := NewFakeClock(time.Now())
+	c := build(t, policy, conformanceSize, setup{clock: clock, evicted: func(_, _ interface{}, r gorsy_cache.EvictionReason) {
+		mu.Lock()
+		reasons[r]++
+		mu.Unlock()
+	}})
+	count := func(r gorsy_cache.EvictionReason) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return reasons[r]
+	}
+
+	c.Set("a", 1)
+	c.Set("a", 2)
+	if n := count(gorsy_cache.EvictionReplaced); n != 1 {
+		t.Fatalf("%d replaced callbacks after overwriting, want 1", n)
+	}
+	c.Remove("a")
+	if n := count(gorsy_cache.EvictionExplicit); n != 1 {
+		t.Fatalf("%d explicit callbacks after Remove, want 1", n)
+	}
+
+	c.SetWithExpire("e", 1, time.Second)
+	clock.Advance(time.Minute)
+	c.CleanExpired()
+	if n := count(gorsy_cache.EvictionExpired); n != 1 {
+		t.Fatalf("%d expired callbacks after cleaning, want 1", n)
+	}
+
+	over := 2 * conformanceSize
+	for i := 0; i < conformanceSize+over; i++ {
+		c.Set(i, i)
+	}
+	if n := count(gorsy_cache.EvictionCapacity); n != over {
+		t.Fatalf("%d capacity callbacks after %d sets over the size, want %d", n, over, over)
+	}
+
+	left := c.Len()
+	c.Flush()
+	if n := count(gorsy_cache.EvictionFlushed); n != left {
+		t.Fatalf("%d flushed callbacks, want %d", n, left)
+	}
+	if n := c.Len(); n != 0 {
+		t.Fatalf("Len() = %d after Flush, want 0", n)
+	}
+}
+
+func testConcurrent(t *testing.T, policy string) {
+	c := build(t, policy, conformanceSize, setup{loader: func(key interface{}) (interface{}, error) {
+		return key, nil
+	}})
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				k := (i*7 + g) % (4 * conformanceSize)
+				switch i % 8 {
+				case 0:
+					c.Remove(k)
+				case 1:
+					c.Has(k)
+				case 2:
+					c.GetOnlyPresent(k)
+				case 3:
+					if v, err := c.Get(k); err != nil || v != k {
+						t.Errorf("Get(%d) = %v, %v", k, v, err)
+						return
+					}
+				case 4:
+					c.SetWithExpire(k, k, time.Hour)
+				case 5:
+					c.TTL(k)
+				case 6:
+					if i%64 == 6 {
+						c.Keys()
+					}
+				default:
+					c.Set(k, k)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if n := c.Len(); n > conformanceSize {
+		t.Fatalf("Len() = %d after the concurrent use, over the size %d", n, conformanceSize)
+	}
+	for _, k := range c.Keys() {
+		if v, ok := c.GetOnlyPresent(k); ok && v != k {
+			t.Fatalf("GetOnlyPresent(%v) = %v", k, v)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+package gorsy_cache
+
+// capacity returns the number of items the store may hold, which is its size lowered by the limit if any.
+func (c *baseCache) capacity() int {
+	if c.limit > 0 && c.limit < c.size {
+		return c.limit
+	}
+	return c.size
+}
+
+// overCapacity reports whether the store holding n items is over its capacity or its max weight.
+func (c *baseCache) overCapacity(n int) bool {
+	return n > c.capacity() || c.overWeight(0)
+}
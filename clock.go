@@ -0,0 +1,76 @@
+package gorsy_cache
+
+import (
+	"reflect"
+	"time"
+)
+
+// Clock tells the time to a cache store, and schedules the purging of it. The caches use the system clock by
+// default, a fake one set by SetClock lets the tests move the time on, see cachetest.FakeClock.
+// The caches sharing a Clock are purged by a single timer of it, so the Clocks are compared by ==. A Clock that
+// isn't comparable, such as a struct holding a func, is only shared by the caches built by the same builder.
+type Clock interface {
+	Now() time.Time
+	// AfterFunc calls f after d, until the Timer is stopped.
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a timer made by Clock.AfterFunc, *time.Timer implements it.
+type Timer interface {
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// systemClock is the Clock of the time package.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// clockRef wraps a Clock that isn't comparable, so that it can key the schedulers by the identity of the wrapper.
+type clockRef struct {
+	Clock
+}
+
+// comparableClock returns the clock if it may be compared by ==, and a wrapper of it otherwise.
+func comparableClock(clock Clock) Clock {
+	if clock == nil || isComparable(clock) {
+		return clock
+	}
+	return &clockRef{clock}
+}
+
+// isComparable reports whether v may be compared by ==, which panics for the values holding a func, a map or
+// a slice, even in a interface field of a comparable type.
+func isComparable(v interface{}) (ok bool) {
+	if !reflect.TypeOf(v).Comparable() {
+		return false
+	}
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	return v == v
+}
+
+// clockOrDefault returns the clock of the store, the system clock if none was set.
+func (c *baseCache) clockOrDefault() Clock {
+	if c.clock == nil {
+		return systemClock{}
+	}
+	return c.clock
+}
+
+// now returns the current time by the clock of the store.
+func (c *baseCache) now() time.Time {
+	if c.clock == nil {
+		return time.Now()
+	}
+	return c.clock.Now()
+}
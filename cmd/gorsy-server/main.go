@@ -0,0 +1,44 @@
+// Command gorsy-server serves a gorsy cache store over the redis protocol.
+//
+//	gorsy-server -addr :6379 -policy arc -size 100000
+//	redis-cli -p 6379 set greeting hello EX 60
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/arianxx/gorsy-cache"
+	"github.com/arianxx/gorsy-cache/server"
+)
+
+func main() {
+	addr := flag.String("addr", ":6379", "TCP address to listen on")
+	policy := flag.String("policy", gorsy_cache.LRU, "eviction policy of the cache store")
+	size := flag.Int("size", 10000, "maximum number of keys")
+	shards := flag.Int("shards", 1, "number of shards of the cache store")
+	flag.Parse()
+
+	builder, err := gorsy_cache.NewShardedBuilder(*policy, *size, *shards)
+	if err != nil {
+		log.Fatalf("build cache error: %s", err)
+	}
+	cache := builder.SetName("gorsy-server").Build()
+	defer cache.Close()
+
+	s := server.New(cache)
+	go func() {
+		ch := make(chan os.Signal, 1)
+		signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+		<-ch
+		s.Close()
+	}()
+
+	log.Printf("serving a %s cache of %d keys on %s", *policy, *size, *addr)
+	if err := s.ListenAndServe(*addr); err != nil && err != server.ErrServerClosed {
+		log.Print(err)
+	}
+}
@@ -0,0 +1,147 @@
+// Command gorsy-sim replays access traces against the cache policies, and reports the hit ratio, the byte hit ratio
+// and the evictions of every policy and size, to pick a policy by the real traffic.
+//
+//	gorsy-sim -format spc -policies lru,lfu,arc -sizes 1000,10000,100000 Financial1.spc
+//	gorsy-sim -format csv -bytes -sizes 67108864 -ttl 10m -json requests.csv
+//
+// The traces are read in the formats:
+//
+//	arc   the traces of the ARC paper, lines of "start count ignored request" of 512-byte blocks
+//	spc   the UMass traces of the Storage Performance Council, lines of "ASU,LBA,size,opcode,timestamp"
+//	keys  a key per line
+//	csv   records of "timestamp,key,size", the timestamps in seconds since the epoch or in RFC 3339
+//
+// Several files are replayed as a single trace, and "-" reads the standard input.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/arianxx/gorsy-cache"
+)
+
+func main() {
+	format := flag.String("format", "keys", "trace format: arc, spc, keys or csv")
+	policies := flag.String("policies", "lru,lfu,arc", `comma separated policies to replay, or "all"`)
+	sizes := flag.String("sizes", "1000", "comma separated cache sizes, in entries or in bytes with -bytes")
+	bytes := flag.Bool("bytes", false, "bound the caches by the total size of the entries instead of the number")
+	ttl := flag.Duration("ttl", 0, "expiration of the entries by the timestamps of the trace, 0 never expires")
+	asJSON := flag.Bool("json", false, "print the results as JSON instead of a table")
+	parallel := flag.Int("parallel", runtime.GOMAXPROCS(0), "number of the caches replaying at a time")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: gorsy-sim [flags] trace...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	names := strings.Split(*policies, ",")
+	if *policies == "all" {
+		names = gorsy_cache.Policies()
+	}
+	var configs []config
+	for _, s := range strings.Split(*sizes, ",") {
+		size, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil || size < 1 {
+			log.Fatalf("invalid size %q", s)
+		}
+		for _, name := range names {
+			configs = append(configs, config{policy: strings.TrimSpace(name), size: size, bytes: *bytes, ttl: *ttl})
+		}
+	}
+
+	t := newTrace()
+	for _, name := range flag.Args() {
+		if err := readTrace(t, name, *format); err != nil {
+			log.Fatalf("read trace %s: %s", name, err)
+		}
+	}
+	if *ttl > 0 && !t.timed {
+		log.Printf("the trace has no timestamps, so no entry expires by -ttl")
+	}
+
+	if *parallel < 1 {
+		*parallel = 1
+	}
+	results := run(configs, t, *parallel)
+	var err error
+	if *asJSON {
+		err = printJSON(os.Stdout, results)
+	} else {
+		err = printTable(os.Stdout, results, *bytes)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func readTrace(t *trace, name, format string) error {
+	if name == "-" {
+		return t.read(os.Stdin, format)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return t.read(f, format)
+}
+
+// run replays the trace against the caches of the configs, parallel at a time, and returns the results in order.
+func run(configs []config, t *trace, parallel int) []result {
+	results := make([]result, len(configs))
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i, cfg := range configs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, cfg config) {
+			defer wg.Done()
+			start := time.Now()
+			results[i] = simulate(cfg, t)
+			log.Printf("replayed %d requests against %s of size %d in %s",
+				len(t.requests), cfg.policy, cfg.size, time.Since(start).Round(time.Millisecond))
+			<-sem
+		}(i, cfg)
+	}
+	wg.Wait()
+	return results
+}
+
+func printJSON(w io.Writer, results []result) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(results)
+}
+
+func printTable(w io.Writer, results []result, bytes bool) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	sizeUnit := "SIZE"
+	if bytes {
+		sizeUnit = "SIZE (BYTES)"
+	}
+	fmt.Fprintf(tw, "POLICY\t%s\tREQUESTS\tHIT RATIO\tBYTE HIT RATIO\tEVICTIONS\tREJECTIONS\tEXPIRATIONS\t\n", sizeUnit)
+	for _, r := range results {
+		if r.Error != "" {
+			fmt.Fprintf(tw, "%s\t%d\t%s\t\t\t\t\t\t\n", r.Policy, r.Size, r.Error)
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.2f%%\t%.2f%%\t%d\t%d\t%d\t\n", r.Policy, r.Size, r.Requests,
+			100*r.HitRatio, 100*r.ByteHitRatio, r.Evictions, r.Rejections, r.Expirations)
+	}
+	return tw.Flush()
+}
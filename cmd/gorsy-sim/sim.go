@@ -0,0 +1,95 @@
+package main
+
+import (
+	"time"
+
+	"github.com/arianxx/gorsy-cache"
+	"github.com/arianxx/gorsy-cache/internal/fakeclock"
+)
+
+// config is a cache replaying a trace.
+type config struct {
+	policy string
+	size   int
+	// bytes bounds the cache by the total size of the entries instead of the number of them.
+	bytes bool
+	ttl   time.Duration
+}
+
+// result is how well a cache did on a trace.
+type result struct {
+	Policy       string  `json:"policy"`
+	Size         int     `json:"size"`
+	Requests     int64   `json:"requests"`
+	Hits         int64   `json:"hits"`
+	HitRatio     float64 `json:"hit_ratio"`
+	Bytes        int64   `json:"bytes"`
+	HitBytes     int64   `json:"hit_bytes"`
+	ByteHitRatio float64 `json:"byte_hit_ratio"`
+	Evictions    int64   `json:"evictions"`
+	// Rejections are the entries larger than the cache in bytes, which are never stored.
+	Rejections  int64  `json:"rejections"`
+	Expirations int64  `json:"expirations"`
+	Error       string `json:"error,omitempty"`
+}
+
+// simulate replays the trace against a cache of the config. A miss stores the requested key, the time of the cache
+// follows the timestamps of the trace, so the entries expire and are purged as they would have been.
+func simulate(cfg config, t *trace) result {
+	res := result{Policy: cfg.policy, Size: cfg.size}
+
+	size := cfg.size
+	if cfg.bytes {
+		size = len(t.keys) + 1
+	}
+	builder, err := gorsy_cache.NewBuilder(cfg.policy, size)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	clock := fakeclock.New(time.Unix(0, 0))
+	builder.SetClock(clock).SetName("gorsy-sim")
+	if cfg.ttl > 0 {
+		builder.SetDefaultExpiration(cfg.ttl)
+	} else {
+		builder.SetDefaultExpiration(gorsy_cache.NoExpiration).SetPurgeInterval(gorsy_cache.NoPurge)
+	}
+	if cfg.bytes {
+		builder.SetWeigher(func(_, value interface{}) int64 {
+			return value.(int64)
+		}).SetMaxWeight(int64(cfg.size))
+	}
+	cache := builder.Build()
+	defer cache.Close()
+
+	var elapsed time.Duration
+	for _, req := range t.requests {
+		if req.at > elapsed {
+			clock.Advance(req.at - elapsed)
+			elapsed = req.at
+		}
+
+		res.Requests++
+		res.Bytes += req.size
+		if _, ok := cache.GetOnlyPresent(req.key); ok {
+			res.Hits++
+			res.HitBytes += req.size
+		} else if cfg.bytes && req.size > int64(cfg.size) {
+			// the cache would reject it as too heavy, and count it as a capacity eviction
+			res.Rejections++
+		} else {
+			cache.Set(req.key, req.size)
+		}
+	}
+
+	stats := cache.Stats()
+	res.Evictions = stats.CapacityEvictions
+	res.Expirations = stats.ExpiredEvictions
+	if res.Requests > 0 {
+		res.HitRatio = float64(res.Hits) / float64(res.Requests)
+	}
+	if res.Bytes > 0 {
+		res.ByteHitRatio = float64(res.HitBytes) / float64(res.Bytes)
+	}
+	return res
+}
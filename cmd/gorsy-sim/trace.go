@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// blockSize is the size of a block requested by the block traces.
+const blockSize = 512
+
+// request is a access of a trace.
+type request struct {
+	key  interface{}
+	size int64
+	// at is the time of the request since the first one, 0 if the trace has no timestamps.
+	at time.Duration
+}
+
+// parser reads the requests of a trace format from r, and passes them to emit in order.
+type parser func(r io.Reader, emit func(key interface{}, size int64, at time.Time)) error
+
+// formats are the trace formats by name.
+var formats = map[string]parser{
+	"arc":  parseARC,
+	"spc":  parseSPC,
+	"keys": parseKeys,
+	"csv":  parseCSV,
+}
+
+// trace is the requests read from the trace files, with the sets of the keys.
+type trace struct {
+	requests []request
+	keys     map[interface{}]struct{}
+	// timed is set if the requests have timestamps, and start is the time of the first of them.
+	timed bool
+	start time.Time
+}
+
+func newTrace() *trace {
+	return &trace{keys: make(map[interface{}]struct{})}
+}
+
+// read appends the requests of r in the format to the trace. The times of the requests are counted from the first
+// request of the trace, so the files read one after the other keep the time between them.
+func (t *trace) read(r io.Reader, format string) error {
+	parse, ok := formats[format]
+	if !ok {
+		return fmt.Errorf("unknown trace format %q", format)
+	}
+
+	return parse(r, func(key interface{}, size int64, at time.Time) {
+		req := request{key: key, size: size}
+		if !at.IsZero() {
+			if t.start.IsZero() {
+				t.start = at
+			}
+			req.at = at.Sub(t.start)
+			t.timed = true
+		}
+		t.requests = append(t.requests, req)
+		t.keys[key] = struct{}{}
+	})
+}
+
+// parseARC reads the traces of the ARC paper, a line of "start count ignored request" is a request of count blocks
+// from the block start.
+func parseARC(r io.Reader, emit func(key interface{}, size int64, at time.Time)) error {
+	return eachLine(r, func(n int, line string) error {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return fmt.Errorf("line %d: want the start block and the number of blocks: %q", n, line)
+		}
+		start, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("line %d: start block: %s", n, err)
+		}
+		count, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("line %d: number of blocks: %s", n, err)
+		}
+		for i := int64(0); i < count; i++ {
+			emit(start+i, blockSize, time.Time{})
+		}
+		return nil
+	})
+}
+
+// parseSPC reads the UMass traces of the Storage Performance Council, a line of "ASU,LBA,size,opcode,timestamp"
+// is a request of the blocks of size bytes from the block LBA of the application storage unit ASU.
+// The timestamp is in seconds.
+func parseSPC(r io.Reader, emit func(key interface{}, size int64, at time.Time)) error {
+	return eachLine(r, func(n int, line string) error {
+		fields := strings.Split(line, ",")
+		if len(fields) < 5 {
+			return fmt.Errorf("line %d: want ASU,LBA,size,opcode,timestamp: %q", n, line)
+		}
+		asu, err := strconv.ParseUint(strings.TrimSpace(fields[0]), 10, 16)
+		if err != nil {
+			return fmt.Errorf("line %d: ASU: %s", n, err)
+		}
+		lba, err := strconv.ParseUint(strings.TrimSpace(fields[1]), 10, 48)
+		if err != nil {
+			return fmt.Errorf("line %d: LBA: %s", n, err)
+		}
+		size, err := strconv.ParseInt(strings.TrimSpace(fields[2]), 10, 64)
+		if err != nil {
+			return fmt.Errorf("line %d: size: %s", n, err)
+		}
+		at, err := parseTime(strings.TrimSpace(fields[4]))
+		if err != nil {
+			return fmt.Errorf("line %d: timestamp: %s", n, err)
+		}
+
+		blocks := (size + blockSize - 1) / blockSize
+		if blocks < 1 {
+			blocks = 1
+		}
+		for i := uint64(0); i < uint64(blocks); i++ {
+			emit(asu<<48|(lba+i), blockSize, at)
+		}
+		return nil
+	})
+}
+
+// parseKeys reads a key per line, every request is of size 1.
+func parseKeys(r io.Reader, emit func(key interface{}, size int64, at time.Time)) error {
+	return eachLine(r, func(_ int, line string) error {
+		emit(line, 1, time.Time{})
+		return nil
+	})
+}
+
+// parseCSV reads the records of "timestamp,key,size", with a optional header. The timestamps are seconds since
+// the epoch, or in RFC 3339.
+func parseCSV(r io.Reader, emit func(key interface{}, size int64, at time.Time)) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = 3
+	cr.TrimLeadingSpace = true
+	for n := 1; ; n++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		at, err := parseTime(record[0])
+		if err != nil {
+			if n == 1 {
+				continue
+			}
+			return fmt.Errorf("record %d: timestamp: %s", n, err)
+		}
+		size, err := strconv.ParseInt(record[2], 10, 64)
+		if err != nil || size < 0 {
+			return fmt.Errorf("record %d: size %q isn't a byte count", n, record[2])
+		}
+		emit(record[1], size, at)
+	}
+}
+
+// parseTime parses a timestamp in seconds since the epoch, or in RFC 3339.
+func parseTime(s string) (time.Time, error) {
+	if secs, err := strconv.ParseFloat(s, 64); err == nil {
+		whole, frac := math.Modf(secs)
+		return time.Unix(int64(whole), int64(frac*1e9)), nil
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+// eachLine calls f with the lines of r not empty or commented out by #, numbered from 1.
+func eachLine(r io.Reader, f func(n int, line string) error) error {
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := f(n, line); err != nil {
+			return err
+		}
+	}
+	return s.Err()
+}
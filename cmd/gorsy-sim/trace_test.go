@@ -0,0 +1,133 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readString(t *testing.T, tr *trace, format, s string) {
+	t.Helper()
+	if err := tr.read(strings.NewReader(s), format); err != nil {
+		t.Fatalf("read %s: %v", format, err)
+	}
+}
+
+func TestParseARC(t *testing.T) {
+	tr := newTrace()
+	readString(t, tr, "arc", "# start count ignored request\n10 2 0 1\n\n7 1 0 2\n10 1 0 3\n")
+
+	want := []request{{key: int64(10), size: blockSize}, {key: int64(11), size: blockSize},
+		{key: int64(7), size: blockSize}, {key: int64(10), size: blockSize}}
+	if !reflect.DeepEqual(tr.requests, want) {
+		t.Errorf("requests %v, want %v", tr.requests, want)
+	}
+	if len(tr.keys) != 3 || tr.timed {
+		t.Errorf("%d keys, timed %v, want 3 keys without timestamps", len(tr.keys), tr.timed)
+	}
+}
+
+func TestParseSPC(t *testing.T) {
+	tr := newTrace()
+	readString(t, tr, "spc", "0,20941264,8192,w,0.551706\n1, 20941264, 100, r, 1.25\n")
+
+	// the 8192 bytes are 16 blocks, and the 100 bytes 1 block of the other ASU.
+	if n := len(tr.requests); n != 17 {
+		t.Fatalf("%d requests, want 17", n)
+	}
+	for i, want := range map[int]request{
+		0:  {key: uint64(20941264), size: blockSize},
+		15: {key: uint64(20941279), size: blockSize},
+		16: {key: uint64(1<<48 | 20941264), size: blockSize, at: 698294 * time.Microsecond},
+	} {
+		got := tr.requests[i]
+		got.at = got.at.Round(time.Microsecond)
+		if got != want {
+			t.Errorf("request %d: %+v, want %+v", i, got, want)
+		}
+	}
+	if !tr.timed {
+		t.Error("the trace has timestamps")
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	tr := newTrace()
+	readString(t, tr, "keys", "a\n  b  \n#c\na\n")
+
+	want := []request{{key: "a", size: 1}, {key: "b", size: 1}, {key: "a", size: 1}}
+	if !reflect.DeepEqual(tr.requests, want) {
+		t.Errorf("requests %v, want %v", tr.requests, want)
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	tr := newTrace()
+	readString(t, tr, "csv", "timestamp,key,size\n100,a,10\n 100.5, b, 20\n1970-01-01T00:01:42Z,a,10\n")
+
+	want := []request{{key: "a", size: 10}, {key: "b", size: 20, at: 500 * time.Millisecond},
+		{key: "a", size: 10, at: 2 * time.Second}}
+	if !reflect.DeepEqual(tr.requests, want) {
+		t.Errorf("requests %v, want %v", tr.requests, want)
+	}
+}
+
+// TestReadFiles reads two files as a single trace, the times of the second one follow the first.
+func TestReadFiles(t *testing.T) {
+	tr := newTrace()
+	readString(t, tr, "csv", "100,a,1\n101,b,1\n")
+	readString(t, tr, "csv", "110,c,1\n")
+
+	if got := tr.requests[2].at; got != 10*time.Second {
+		t.Errorf("the request of the second file is at %s, want 10s", got)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, tt := range []struct {
+		format, trace string
+	}{
+		{"arc", "10\n"},
+		{"arc", "x 1 0 1\n"},
+		{"spc", "0,1,512,r\n"},
+		{"spc", "0,1,512,r,soon\n"},
+		{"spc", "70000,1,512,r,0\n"},
+		{"csv", "100,a\n"},
+		{"csv", "100,a,1\nsoon,b,1\n"},
+		{"csv", "100,a,-1\n"},
+		{"nosuch", "a\n"},
+	} {
+		if err := newTrace().read(strings.NewReader(tt.trace), tt.format); err == nil {
+			t.Errorf("read %s %q succeeded, want a error", tt.format, tt.trace)
+		}
+	}
+}
+
+// TestSimulateTTL replays a timed trace, the entries expire by the time of the trace.
+func TestSimulateTTL(t *testing.T) {
+	tr := newTrace()
+	readString(t, tr, "csv", "0,a,1\n5,a,1\n20,a,1\n21,a,1\n")
+
+	res := simulate(config{policy: "lru", size: 10, ttl: 10 * time.Second}, tr)
+	if res.Error != "" {
+		t.Fatal(res.Error)
+	}
+	if res.Requests != 4 || res.Hits != 2 {
+		t.Errorf("%d hits of %d requests, want 2 of 4", res.Hits, res.Requests)
+	}
+}
+
+// TestSimulateRejections counts the entries larger than a cache in bytes apart from the evictions.
+func TestSimulateRejections(t *testing.T) {
+	tr := newTrace()
+	readString(t, tr, "csv", "0,a,50\n1,b,200\n2,a,50\n3,b,200\n")
+
+	res := simulate(config{policy: "lru", size: 100, bytes: true}, tr)
+	if res.Error != "" {
+		t.Fatal(res.Error)
+	}
+	if res.Hits != 1 || res.Rejections != 2 || res.Evictions != 0 {
+		t.Errorf("%d hits, %d rejections and %d evictions, want 1, 2 and 0", res.Hits, res.Rejections, res.Evictions)
+	}
+}
@@ -0,0 +1,22 @@
+package gorsy_cache_test
+
+import (
+	"testing"
+
+	gorsy_cache "github.com/arianxx/gorsy-cache"
+	"github.com/arianxx/gorsy-cache/cachetest"
+)
+
+func TestConformance(t *testing.T) {
+	for _, policy := range []string{
+		gorsy_cache.SIMPLE,
+		gorsy_cache.LRU,
+		gorsy_cache.LFU,
+		gorsy_cache.ARC,
+		gorsy_cache.TINYLFU,
+	} {
+		t.Run(policy, func(t *testing.T) {
+			cachetest.RunConformance(t, policy)
+		})
+	}
+}
@@ -9,9 +9,14 @@ type KeyNotFoundError struct {
 }
 
 func (e *KeyNotFoundError) Error() string {
-	s := "`%s`: key `%s` not found in the cache store"
+	s := "`%s`: key `%v` not found in the cache store"
 	if e.Err != nil {
 		s += " with loader function error: " + e.Err.Error()
 	}
 	return fmt.Sprintf(s, e.Name, e.Key)
 }
+
+// Unwrap returns the error of the loader function, so it can be matched by errors.Is and errors.As.
+func (e *KeyNotFoundError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"container/list"
+	"fmt"
+
+	"github.com/arianxx/gorsy-cache"
+)
+
+// fifo evicts the key inserted earliest, whatever it has been accessed.
+type fifo struct {
+	l *list.List
+	m map[interface{}]*list.Element
+}
+
+func (f *fifo) Init(size int) {
+	f.l = list.New()
+	f.m = make(map[interface{}]*list.Element, size)
+}
+
+func (f *fifo) OnAccess(key interface{}) {}
+
+func (f *fifo) OnInsert(key interface{}) {
+	f.m[key] = f.l.PushBack(key)
+}
+
+func (f *fifo) Victim() (interface{}, bool) {
+	e := f.l.Front()
+	if e == nil {
+		return nil, false
+	}
+	return e.Value, true
+}
+
+func (f *fifo) OnRemove(key interface{}) {
+	if e, ok := f.m[key]; ok {
+		f.l.Remove(e)
+		delete(f.m, key)
+	}
+}
+
+func main() {
+	err := gorsy_cache.RegisterPolicy("fifo", func() gorsy_cache.Policy {
+		return &fifo{}
+	})
+	if err != nil {
+		panic("register policy error: " + err.Error())
+	}
+
+	builder, err := gorsy_cache.NewBuilder("fifo", 2)
+	if err != nil {
+		panic("build cache error: " + err.Error())
+	}
+	cache := builder.SetName("fifo").Build()
+	cache.Set(1, 2)
+	cache.Set(2, 3)
+	fmt.Println(cache.Get(1))
+	cache.Set(3, 4)
+	fmt.Println(cache.Has(1), cache.Has(2), cache.Has(3))
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/arianxx/gorsy-cache"
+	"github.com/arianxx/gorsy-cache/typed"
+)
+
+func loader(key int) (string, error) {
+	return strconv.Itoa(key * 2), nil
+}
+
+func beforeEvict(key int, value string) {
+	fmt.Printf("%d: %s was be removed\n", key, value)
+}
+
+func main() {
+	builder, err := typed.NewBuilder[int, string](gorsy_cache.ARC, 10)
+	if err != nil {
+		panic("build cache error: " + err.Error())
+	}
+	cache := builder.
+		SetName("typed rocket").
+		SetLoaderFunc(loader).
+		SetBeforeEvictedFunc(beforeEvict).
+		Build()
+	cache.Set(1, "one")
+	v, _ := cache.Get(1)
+	fmt.Println(v + "!")
+	fmt.Println(cache.Get(21))
+	cache.Remove(1)
+	fmt.Println(cache.Keys(), cache.Len())
+}
@@ -0,0 +1,141 @@
+package gorsy_cache
+
+import (
+	"container/heap"
+	"math/rand"
+	"time"
+)
+
+// expirationIndex holds the items with a expiration, so that the expired items can be collected without
+// scanning the whole store. It is a min-heap ordered by the expiration in the PurgeIndexed mode,
+// and a unordered set sampled at random in the PurgeSampled mode.
+type expirationIndex []*baseItem
+
+func (x expirationIndex) Len() int           { return len(x) }
+func (x expirationIndex) Less(i, j int) bool { return x[i].expiration.Before(*x[j].expiration) }
+func (x expirationIndex) Swap(i, j int) {
+	x[i].expIndex, x[j].expIndex = j, i
+	x[i], x[j] = x[j], x[i]
+}
+
+func (x *expirationIndex) Push(v interface{}) {
+	v.(*baseItem).expIndex = len(*x)
+	*x = append(*x, v.(*baseItem))
+}
+
+func (x *expirationIndex) Pop() interface{} {
+	v, n := (*x)[len(*x)-1], (*x)[:len(*x)-1]
+	// the slot is cleared so that the item is never kept alive by the backing array.
+	(*x)[len(*x)-1] = nil
+	*x = n
+	v.expIndex = -1
+	return v
+}
+
+// index adds the item to the expiration index, or updates its position, after its expiration was changed.
+// It must be called with the lock held.
+func (c *baseCache) index(item *baseItem) {
+	switch {
+	case item.expiration == nil:
+		c.unindex(item)
+	case item.expIndex < 0 && c.purgeMode == PurgeSampled:
+		item.expIndex = len(c.expirations)
+		c.expirations = append(c.expirations, item)
+	case item.expIndex < 0:
+		heap.Push(&c.expirations, item)
+	case c.purgeMode != PurgeSampled:
+		heap.Fix(&c.expirations, item.expIndex)
+	}
+}
+
+// unindex removes the item from the expiration index.
+func (c *baseCache) unindex(item *baseItem) {
+	if item.expIndex < 0 {
+		return
+	}
+	if c.purgeMode != PurgeSampled {
+		heap.Remove(&c.expirations, item.expIndex)
+		return
+	}
+
+	// the sampled index is unordered, the last item takes the place of the removed one.
+	i, last := item.expIndex, len(c.expirations)-1
+	c.expirations.Swap(i, last)
+	c.expirations[last] = nil
+	c.expirations = c.expirations[:last]
+	item.expIndex = -1
+}
+
+// popExpired pops a item which has been expired at now. The indexed mode pops the item expired the earliest,
+// the sampled mode the first expired one of a sample.
+func (c *baseCache) popExpired(now time.Time) (*baseItem, bool) {
+	if c.purgeMode == PurgeSampled {
+		for i := 0; i < c.purgeSamples && len(c.expirations) > 0; i++ {
+			item := c.expirations[rand.Intn(len(c.expirations))]
+			if item.expiration.Before(now) {
+				c.unindex(item)
+				return item, true
+			}
+		}
+		return nil, false
+	}
+
+	if len(c.expirations) == 0 || !c.expirations[0].expiration.Before(now) {
+		return nil, false
+	}
+	return heap.Pop(&c.expirations).(*baseItem), true
+}
+
+// cleanExpired collects the expired items by the index, calling remove with the lock held for each of them.
+// With a purge batch size, the lock is released between the batches, so the readers are never blocked for long.
+func (c *baseCache) cleanExpired(remove func(key interface{})) int {
+	if c.purgeMode == PurgeSampled {
+		return c.cleanSampled(remove)
+	}
+
+	n := 0
+	for {
+		c.Lock()
+		now := c.now()
+		batch := 0
+		for c.purgeBatch <= 0 || batch < c.purgeBatch {
+			item, ok := c.popExpired(now)
+			if !ok {
+				break
+			}
+			remove(item.key)
+			batch++
+		}
+		c.unlockAndNotify()
+
+		n += batch
+		if c.purgeBatch <= 0 || batch < c.purgeBatch {
+			return n
+		}
+	}
+}
+
+// cleanSampled checks purgeSamples random items with a expiration per round, and starts another round while
+// more than purgeSampleRate of them were expired. The lock is released between the rounds.
+func (c *baseCache) cleanSampled(remove func(key interface{})) int {
+	n := 0
+	for {
+		c.Lock()
+		now := c.now()
+		sampled, expired := 0, 0
+		for ; sampled < c.purgeSamples && len(c.expirations) > 0; sampled++ {
+			item := c.expirations[rand.Intn(len(c.expirations))]
+			if item.expiration.Before(now) {
+				c.unindex(item)
+				remove(item.key)
+				expired++
+			}
+		}
+		c.unlockAndNotify()
+
+		n += expired
+		if sampled == 0 || float64(expired) <= float64(sampled)*c.purgeSampleRate {
+			return n
+		}
+	}
+}
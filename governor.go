@@ -0,0 +1,208 @@
+package gorsy_cache
+
+import (
+	"math"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+const (
+	defaultGovernorInterval = time.Second
+	defaultGovernorStep     = 0.1
+
+	// The caches are shrunk while the heap is above governorHigh of the target,
+	// and grown back while it is below governorLow of it.
+	governorHigh = 0.9
+	governorLow  = 0.7
+)
+
+// Governor shrinks the caches registered to it when the heap of the process nears a target, and grows them back
+// toward their sizes when the pressure eases. The items are evicted by the policies of the caches as if the
+// caches were full, so the eviction callbacks are called with EvictionCapacity.
+type Governor struct {
+	mu       sync.Mutex
+	target   int64
+	interval time.Duration
+	step     float64
+	// caches are the caches registered, the closed ones are forgotten by Check.
+	caches map[Cache]struct{}
+	// limits are the capacities the caches are shrunk to, a cache not in it has its whole size.
+	limits map[Cache]int
+	// lastGC is the number of the GC cycles when the caches were shrunk last time, the heap isn't checked
+	// again before the next cycle collects the evicted items.
+	lastGC uint32
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// NewGovernor returns a governor keeping the heap under target bytes. With a target of 0 or less,
+// the memory limit of the runtime set by debug.SetMemoryLimit or GOMEMLIMIT is the target.
+func NewGovernor(target int64) *Governor {
+	return &Governor{
+		target:   target,
+		interval: defaultGovernorInterval,
+		step:     defaultGovernorStep,
+		caches:   make(map[Cache]struct{}),
+		limits:   make(map[Cache]int),
+	}
+}
+
+// Register makes the governor manage c, a cache returned by Build.
+func (g *Governor) Register(c Cache) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.caches[c] = struct{}{}
+}
+
+// Unregister stops managing c, and gives it its whole size back.
+func (g *Governor) Unregister(c Cache) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.forget(c)
+}
+
+// forget drops c, lifting its limit, it must be called with g.mu held.
+func (g *Governor) forget(c Cache) {
+	delete(g.caches, c)
+	if _, ok := g.limits[c]; ok {
+		delete(g.limits, c)
+		c.setLimit(0)
+	}
+}
+
+// SetInterval sets the period of the checks of the heap, 1 second by default.
+func (g *Governor) SetInterval(d time.Duration) *Governor {
+	g.interval = d
+	return g
+}
+
+// SetStep sets the fraction of the items evicted from every cache by a check under pressure,
+// which is also the fraction of the size given back by a check after the pressure eased. 0.1 by default.
+func (g *Governor) SetStep(f float64) *Governor {
+	g.step = f
+	return g
+}
+
+// Start checks the heap every interval in a goroutine, until Stop is called.
+func (g *Governor) Start() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.stop != nil {
+		return
+	}
+	g.stop, g.done = make(chan struct{}), make(chan struct{})
+	go g.run(g.stop, g.done)
+}
+
+func (g *Governor) run(stop, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(g.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			g.Check()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Stop stops the checks, and gives the caches their whole sizes back.
+func (g *Governor) Stop() {
+	g.mu.Lock()
+	stop, done := g.stop, g.done
+	g.stop, g.done = nil, nil
+	g.mu.Unlock()
+
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for c := range g.limits {
+		c.setLimit(0)
+		delete(g.limits, c)
+	}
+}
+
+// Check checks the heap once, shrinking or growing the caches by a step if needed.
+func (g *Governor) Check() {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	target := g.target
+	if target <= 0 {
+		target = debug.SetMemoryLimit(-1)
+		if target == math.MaxInt64 {
+			return
+		}
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	// forget the caches closed since.
+	for c := range g.caches {
+		if h, ok := c.(*handle); ok && h.isClosed() {
+			g.forget(c)
+		}
+	}
+
+	switch heap := float64(m.HeapAlloc); {
+	case heap > float64(target)*governorHigh:
+		if m.NumGC == g.lastGC {
+			return
+		}
+		g.lastGC = m.NumGC
+		for c := range g.caches {
+			g.shrink(c)
+		}
+	case heap < float64(target)*governorLow:
+		for c := range g.caches {
+			g.grow(c)
+		}
+	}
+}
+
+// shrink lowers the capacity of c by a step of the items it holds, so the caches much larger than their
+// contents, such as the ones bounded by the weight, are shrunk as well. A empty cache is left as it is,
+// it holds no memory to release, and a limit taken from its length would pin it to a single item.
+func (g *Governor) shrink(c Cache) {
+	n := c.Len()
+	if n == 0 {
+		return
+	}
+	limit, ok := g.limits[c]
+	if !ok {
+		limit = c.getBaseCache().size
+	}
+	limit = max(int(float64(min(limit, n))*(1-g.step)), 1)
+	g.limits[c] = limit
+	c.setLimit(limit)
+}
+
+// grow raises the capacity of a shrunk cache by a step of its size, lifting the limit once it reaches the size.
+func (g *Governor) grow(c Cache) {
+	limit, ok := g.limits[c]
+	if !ok {
+		return
+	}
+	size := c.getBaseCache().size
+	limit += max(int(float64(size)*g.step), 1)
+	if limit >= size {
+		delete(g.limits, c)
+		c.setLimit(0)
+		return
+	}
+	g.limits[c] = limit
+	c.setLimit(limit)
+}
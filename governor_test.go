@@ -0,0 +1,85 @@
+package gorsy_cache
+
+import (
+	"runtime"
+	"testing"
+)
+
+func governed(t *testing.T, n int) Cache {
+	t.Helper()
+	b, err := NewBuilder(LRU, n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := b.SetPurgeInterval(NoPurge).SetDefaultExpiration(NoExpiration).Build()
+	t.Cleanup(func() { c.Close() })
+	for i := 0; i < n; i++ {
+		c.Set(i, i)
+	}
+	return c
+}
+
+// TestGovernorCheck shrinks the registered caches by a step a GC cycle while the heap is over the target,
+// and grows them back once it is under.
+func TestGovernorCheck(t *testing.T) {
+	c, other := governed(t, 100), governed(t, 100)
+	g := NewGovernor(1)
+	g.Register(c)
+
+	runtime.GC()
+	g.Check()
+	if n := c.Len(); n != 90 {
+		t.Errorf("Len() = %d after the first check, want 90", n)
+	}
+	g.Check()
+	if n := c.Len(); n != 90 {
+		t.Errorf("Len() = %d after a check in the same GC cycle, want 90", n)
+	}
+	runtime.GC()
+	g.Check()
+	if n := c.Len(); n != 81 || g.limits[c] != 81 {
+		t.Errorf("Len() = %d and limit %d after the second GC cycle, want 81", n, g.limits[c])
+	}
+	if n := other.Len(); n != 100 {
+		t.Errorf("the cache not registered is shrunk to %d", n)
+	}
+
+	g.target = 1 << 62
+	g.Check()
+	if limit := g.limits[c]; limit != 91 {
+		t.Errorf("limit %d after growing, want 91", limit)
+	}
+	g.Check()
+	if _, ok := g.limits[c]; ok {
+		t.Errorf("limit %d left once grown over the size", g.limits[c])
+	}
+	for i := 100; i < 200; i++ {
+		c.Set(i, i)
+	}
+	if n := c.Len(); n != 100 {
+		t.Errorf("Len() = %d once grown back, want 100", n)
+	}
+}
+
+func TestGovernorUnregister(t *testing.T) {
+	c, closed := governed(t, 100), governed(t, 100)
+	g := NewGovernor(1)
+	g.Register(c)
+	g.Register(closed)
+	runtime.GC()
+	g.Check()
+
+	g.Unregister(c)
+	for i := 100; i < 200; i++ {
+		c.Set(i, i)
+	}
+	if n := c.Len(); n != 100 {
+		t.Errorf("Len() = %d after Unregister, want the whole size", n)
+	}
+
+	closed.Close()
+	g.Check()
+	if len(g.caches) != 0 || len(g.limits) != 0 {
+		t.Errorf("%d caches and %d limits left, want none", len(g.caches), len(g.limits))
+	}
+}
@@ -0,0 +1,258 @@
+package gorsy_cache
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrClosed is returned by the operations of a closed cache store.
+var ErrClosed = errors.New("cache store has been closed")
+
+// handle is the Cache returned by Build. It owns the lifecycle of the store, the operations after Close
+// are rejected without touching the store. The store may be swapped by Migrate, which retires the old store
+// first: the operations taking the write lock of a retired store are run again on the new one by do, so that
+// none of them is lost by the swap. The handle holds no lock of its own during the operations, so the callbacks
+// and the loaders may use the cache while a migration is waiting.
+type handle struct {
+	closed int32
+	// mu serializes Migrate and Close.
+	mu sync.Mutex
+	c  atomic.Value // of storeRef
+}
+
+// storeRef holds the store of a handle, as a atomic.Value only holds a single concrete type.
+type storeRef struct {
+	c Cache
+	// swapped is closed once the store has been replaced by Migrate.
+	swapped chan struct{}
+}
+
+func newStoreRef(c Cache) storeRef {
+	return storeRef{c, make(chan struct{})}
+}
+
+func (h *handle) ref() storeRef {
+	return h.c.Load().(storeRef)
+}
+
+func (h *handle) store() Cache {
+	return h.ref().c
+}
+
+// do runs op on the store. If the store has been retired by Migrate, op is run again on the new store once it
+// has been swapped in. The operation of a retired store panics with errRetired before changing it, unless it
+// takes the lock several times, such as the purging, whose earlier changes have been carried over to the new
+// store, so running it again as a whole is safe.
+func (h *handle) do(op func(c Cache)) {
+	for {
+		ref := h.ref()
+		if tryOn(ref.c, op) {
+			return
+		}
+		<-ref.swapped
+		if h.isClosed() {
+			return
+		}
+	}
+}
+
+// tryOn runs op on c, it reports false if c has been retired.
+func tryOn(c Cache, op func(c Cache)) (done bool) {
+	defer func() {
+		if !done {
+			if r := recover(); r != nil && r != errRetired {
+				panic(r)
+			}
+		}
+	}()
+
+	op(c)
+	return true
+}
+
+func (h *handle) isClosed() bool {
+	return atomic.LoadInt32(&h.closed) != 0
+}
+
+// Close stops purging the store and releases its items, calling the callbacks for every item left
+// if SetEvictOnClose was set. The later operations return ErrClosed or do nothing.
+func (h *handle) Close() error {
+	if !atomic.CompareAndSwapInt32(&h.closed, 0, 1) {
+		return ErrClosed
+	}
+
+	stopPurge(h)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	c := h.store()
+	bc := c.getBaseCache()
+	if bc.evictOnClose {
+		c.Flush()
+	} else {
+		c.drop()
+	}
+	if bc.workers != nil {
+		bc.workers.close()
+	}
+	return nil
+}
+
+func (h *handle) getBaseCache() *baseCache {
+	return h.store().getBaseCache()
+}
+
+func (h *handle) Init() {
+	if h.isClosed() {
+		return
+	}
+	h.do(func(c Cache) { c.Init() })
+}
+
+func (h *handle) Get(key interface{}) (v interface{}, err error) {
+	if h.isClosed() {
+		return nil, ErrClosed
+	}
+	h.do(func(c Cache) { v, err = c.Get(key) })
+	return v, err
+}
+
+func (h *handle) GetOnlyPresent(key interface{}) (v interface{}, ok bool) {
+	if h.isClosed() {
+		return nil, false
+	}
+	h.do(func(c Cache) { v, ok = c.GetOnlyPresent(key) })
+	return v, ok
+}
+
+func (h *handle) Set(key, value interface{}) {
+	if h.isClosed() {
+		return
+	}
+	h.do(func(c Cache) { c.Set(key, value) })
+}
+
+func (h *handle) SetWithExpire(key, value interface{}, expiration time.Duration) {
+	if h.isClosed() {
+		return
+	}
+	h.do(func(c Cache) { c.SetWithExpire(key, value, expiration) })
+}
+
+func (h *handle) Has(key interface{}) bool {
+	if h.isClosed() {
+		return false
+	}
+	return h.store().Has(key)
+}
+
+func (h *handle) TTL(key interface{}) (time.Duration, bool) {
+	if h.isClosed() {
+		return 0, false
+	}
+	return h.store().TTL(key)
+}
+
+func (h *handle) Remove(key interface{}) (ok bool) {
+	if h.isClosed() {
+		return false
+	}
+	h.do(func(c Cache) { ok = c.Remove(key) })
+	return ok
+}
+
+func (h *handle) Keys() []interface{} {
+	if h.isClosed() {
+		return []interface{}{}
+	}
+	return h.store().Keys()
+}
+
+func (h *handle) CleanExpired() (n int) {
+	if h.isClosed() {
+		return 0
+	}
+	h.do(func(c Cache) { n = c.CleanExpired() })
+	return n
+}
+
+func (h *handle) Flush() {
+	if h.isClosed() {
+		return
+	}
+	h.do(func(c Cache) { c.Flush() })
+}
+
+func (h *handle) Len() int {
+	if h.isClosed() {
+		return 0
+	}
+	return h.store().Len()
+}
+
+func (h *handle) Resize(n int) {
+	if h.isClosed() {
+		return
+	}
+	h.do(func(c Cache) { c.Resize(n) })
+}
+
+// Stats keeps working after Close, so that the final statistics can be collected.
+func (h *handle) Stats() Stats {
+	return h.store().Stats()
+}
+
+func (h *handle) ResetStats() {
+	h.store().ResetStats()
+}
+
+func (h *handle) SaveTo(w io.Writer) error {
+	if h.isClosed() {
+		return ErrClosed
+	}
+	return h.store().SaveTo(w)
+}
+
+// LoadFrom decodes the snapshot once, and restores it by restore, which may run again after a migration.
+func (h *handle) LoadFrom(r io.Reader) error {
+	if h.isClosed() {
+		return ErrClosed
+	}
+	return loadFrom(h, r)
+}
+
+func (h *handle) drop() {
+	if h.isClosed() {
+		return
+	}
+	h.do(func(c Cache) { c.drop() })
+}
+
+func (h *handle) setLimit(n int) {
+	if h.isClosed() {
+		return
+	}
+	h.do(func(c Cache) { c.setLimit(n) })
+}
+
+func (h *handle) snapshot() *snapshot {
+	if h.isClosed() {
+		return &snapshot{Policy: h.store().getBaseCache().policy}
+	}
+	return h.store().snapshot()
+}
+
+func (h *handle) restore(s *snapshot) {
+	if h.isClosed() {
+		return
+	}
+	h.do(func(c Cache) { c.restore(s) })
+}
+
+func (h *handle) setRetired(retired bool) {
+	h.store().setRetired(retired)
+}
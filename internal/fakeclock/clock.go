@@ -0,0 +1,122 @@
+// Package fakeclock is a gorsy_cache.Clock whose time is moved on by hand, shared by cachetest and by the
+// simulator replaying the traces.
+package fakeclock
+
+import (
+	"sync"
+	"time"
+
+	gorsy_cache "github.com/arianxx/gorsy-cache"
+)
+
+// Clock is a gorsy_cache.Clock whose time only moves on by Advance. The timers due are fired by Advance in its
+// own goroutine in the order of their times, so the purging scheduled by the clock has been done when Advance
+// returns.
+type Clock struct {
+	mu  sync.Mutex
+	now time.Time
+	// timers are the active timers, those fired or stopped are removed.
+	timers []*timer
+}
+
+// New returns a clock starting at now.
+func New(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *Clock) AfterFunc(d time.Duration, f func()) gorsy_cache.Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &timer{c: c, when: c.now.Add(d), f: f, active: true}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// Advance moves the time on by d, firing the timers due on the way. While a timer is fired, the time is the time
+// it was set to, and the timers set by it are fired as well if they are due by the end.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	end := c.now.Add(d)
+	for {
+		t := c.next(end)
+		if t == nil {
+			break
+		}
+		if t.when.After(c.now) {
+			c.now = t.when
+		}
+		c.deactivate(t)
+
+		c.mu.Unlock()
+		t.f()
+		c.mu.Lock()
+	}
+	if end.After(c.now) {
+		c.now = end
+	}
+}
+
+// deactivate marks t inactive and removes it from the timers, c.mu must be held.
+func (c *Clock) deactivate(t *timer) {
+	t.active = false
+	for i, o := range c.timers {
+		if o == t {
+			n := len(c.timers) - 1
+			copy(c.timers[i:], c.timers[i+1:])
+			c.timers[n] = nil
+			c.timers = c.timers[:n]
+			return
+		}
+	}
+}
+
+// next returns the earliest active timer due by end, the earliest set first among the timers of the same time.
+func (c *Clock) next(end time.Time) *timer {
+	var next *timer
+	for _, t := range c.timers {
+		if t.active && !t.when.After(end) && (next == nil || t.when.Before(next.when)) {
+			next = t
+		}
+	}
+	return next
+}
+
+type timer struct {
+	c      *Clock
+	when   time.Time
+	f      func()
+	active bool
+}
+
+func (t *timer) Stop() bool {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+
+	active := t.active
+	if active {
+		t.c.deactivate(t)
+	}
+	return active
+}
+
+func (t *timer) Reset(d time.Duration) bool {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+
+	active := t.active
+	if !active {
+		t.c.timers = append(t.c.timers, t)
+	}
+	t.when, t.active = t.c.now.Add(d), true
+	return active
+}
@@ -0,0 +1,33 @@
+package fakeclock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimers(t *testing.T) {
+	c := New(time.Unix(0, 0))
+	fired := 0
+	for i := 1; i <= 3; i++ {
+		c.AfterFunc(time.Duration(i)*time.Second, func() { fired++ })
+	}
+	stopped := c.AfterFunc(time.Minute, func() { fired++ })
+
+	c.Advance(2 * time.Second)
+	if fired != 2 || len(c.timers) != 2 {
+		t.Errorf("%d fired, %d timers left, want 2 and 2", fired, len(c.timers))
+	}
+	if !stopped.Stop() || stopped.Stop() {
+		t.Error("Stop reports the timer active once")
+	}
+	if len(c.timers) != 1 {
+		t.Errorf("%d timers left after Stop, want 1", len(c.timers))
+	}
+
+	// a stopped timer is fired again once reset
+	stopped.Reset(time.Second)
+	c.Advance(time.Hour)
+	if fired != 4 || len(c.timers) != 0 {
+		t.Errorf("%d fired, %d timers left, want 4 and 0", fired, len(c.timers))
+	}
+}
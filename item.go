@@ -1,28 +1,69 @@
 package gorsy_cache
 
-import "time"
+import (
+	"log"
+	"time"
+)
 
 type baseItem struct {
 	key, value interface{}
 	expiration *time.Time
+	// expIndex is the position of the item in the expiration index, -1 if it is not indexed.
+	expIndex int
+	// weight is the weight of the item counted in the store, see SetWeigher.
+	weight int64
 }
 
-func (s *baseItem) isExpired() bool {
+func newBaseItem(key, value interface{}) baseItem {
+	return baseItem{key: key, value: value, expIndex: -1}
+}
+
+func (s *baseItem) isExpired(now time.Time) bool {
 	if s.expiration == nil {
 		return false
 	}
 
-	return s.expiration.Before(time.Now())
+	return s.expiration.Before(now)
 }
 
 func (s *baseItem) setExpiration(expiration time.Duration, c *baseCache) {
 	if expiration == DefaultExpiration {
 		expiration = c.Expiration
+	} else if c.legacySeconds {
+		expiration = c.legacyDuration(expiration)
 	}
 	if expiration != NoExpiration {
-		t := time.Now().Add(expiration * time.Second)
+		t := c.now().Add(expiration)
 		s.expiration = &t
 	} else {
 		s.expiration = nil
 	}
+	c.index(s)
+}
+
+// ttl returns the remaining time to live of the item at now, NoExpiration if it never expires.
+func (s *baseItem) ttl(now time.Time) time.Duration {
+	if s.expiration == nil {
+		return NoExpiration
+	}
+
+	return s.expiration.Sub(now)
+}
+
+// bareSeconds takes a duration under minDuration given to the builder as a bare number of seconds, such as 60,
+// and logs the conversion so that the call site gets fixed.
+func bareSeconds(what string, d time.Duration) time.Duration {
+	if d <= 0 || d >= minDuration {
+		return d
+	}
+	log.Printf("gorsy_cache: %s %s is taken as %d seconds, pass a duration such as %d*time.Second", what, d, d, d)
+	return d * time.Second
+}
+
+// legacyDuration converts a duration counted in seconds by a LegacySeconds cache, keeping the sentinels.
+func (c *baseCache) legacyDuration(d time.Duration) time.Duration {
+	if d <= 0 {
+		return d
+	}
+	return d * time.Second
 }
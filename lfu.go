@@ -2,6 +2,8 @@ package gorsy_cache
 
 import (
 	"container/heap"
+	"io"
+	"sort"
 	"time"
 )
 
@@ -19,6 +21,8 @@ type lfuCache struct {
 	baseCache
 	items map[interface{}]*lfuItem
 	heap  lfuHeap
+	// tick counts the accesses, it orders the items of the same frequency from the least recently used.
+	tick uint64
 }
 
 func (c *lfuCache) getBaseCache() *baseCache {
@@ -26,116 +30,144 @@ func (c *lfuCache) getBaseCache() *baseCache {
 }
 
 func (c *lfuCache) Init() {
+	c.resetItems()
 	c.items = make(map[interface{}]*lfuItem, c.size)
 	c.heap = make(lfuHeap, 0)
 }
 
 func (c *lfuCache) Get(key interface{}) (interface{}, error) {
-	c.RLock()
-	defer c.RUnlock()
+	c.Lock()
+	v, err := c.get(key)
+	c.Unlock()
+	if err == nil {
+		c.stats.hit()
+		return v, nil
+	}
 
-	return c.get(key, true)
+	c.stats.miss()
+	return c.load(key, c.get, c.Set)
 }
 
 func (c *lfuCache) GetOnlyPresent(key interface{}) (interface{}, bool) {
-	c.RLock()
-	defer c.RUnlock()
+	c.Lock()
+	defer c.Unlock()
 
-	v, err := c.get(key, false)
+	v, err := c.get(key)
 	if err != nil {
+		c.stats.miss()
 		return nil, false
 	} else {
+		c.stats.hit()
 		return v, true
 	}
 }
 
-func (c *lfuCache) get(key interface{}, fromLoader bool) (interface{}, error) {
+func (c *lfuCache) get(key interface{}) (interface{}, error) {
 	item, ok := c.items[key]
-	if ok && !item.isExpired() {
+	if ok && !item.isExpired(c.now()) {
 		item.freq++
+		item.used = c.next()
 		heap.Fix(&c.heap, item.index)
 		return item.value, nil
 	}
 
-	if fromLoader {
-		return c.getFromLoader(key)
-	} else {
-		return nil, &KeyNotFoundError{}
-	}
-}
-
-func (c *lfuCache) getFromLoader(key interface{}) (interface{}, error) {
-	var v interface{}
-	var err error
-	if c.LoaderFunc != nil {
-		v, err = c.LoaderFunc(key)
-		if err != nil {
-			return nil, &KeyNotFoundError{c.Name, key, err}
-		}
-	}
-
-	return v, &KeyNotFoundError{c.Name, key, nil}
+	return nil, &KeyNotFoundError{c.Name, key, nil}
 }
 
 func (c *lfuCache) Set(key, value interface{}) {
 	c.Lock()
-	defer c.Unlock()
+	defer c.unlockAndNotify()
 
 	c.set(key, value, DefaultExpiration)
 }
 
 func (c *lfuCache) set(k, v interface{}, e time.Duration) {
+	w := c.weigh(k, v)
+	if c.tooHeavy(w) {
+		c.remove(k, EvictionCapacity)
+		c.reject(k, v)
+		return
+	}
+
 	ele, ok := c.items[k]
 	if ok {
+		old := ele.value
 		ele.value = v
 		ele.setExpiration(e, &c.baseCache)
+		c.setWeight(&ele.baseItem, w)
+		c.evicted(k, old, EvictionReplaced)
+		for c.overWeight(0) {
+			c.evict(1)
+		}
 		return
 	}
 
-	if len(c.items) == c.size {
+	for len(c.items) > 0 && len(c.items) >= c.capacity() {
+		c.evict(1)
+	}
+	for c.overWeight(w) {
 		c.evict(1)
 	}
 
-	item := &lfuItem{baseItem{k, v, nil}, 0, 0}
+	item := &lfuItem{baseItem: newBaseItem(k, v), used: c.next()}
 	item.setExpiration(e, &c.baseCache)
+	c.setWeight(&item.baseItem, w)
 	heap.Push(&c.heap, item)
 	c.items[k] = item
 }
 
+// next returns the tick of a access.
+func (c *lfuCache) next() uint64 {
+	c.tick++
+	return c.tick
+}
+
 func (c *lfuCache) SetWithExpire(k, v interface{}, e time.Duration) {
 	c.Lock()
-	defer c.Unlock()
+	defer c.unlockAndNotify()
 
 	c.set(k, v, e)
 }
 
 func (c *lfuCache) evict(size int) {
 	for i := 0; i < size; i++ {
-		e := heap.Pop(&c.heap)
-		delete(c.items, e.(*lruItem).key)
+		item := heap.Pop(&c.heap).(*lfuItem)
+		delete(c.items, item.key)
+		c.forget(&item.baseItem)
+		c.evicted(item.key, item.value, EvictionCapacity)
 	}
 }
 
+func (c *lfuCache) TTL(key interface{}) (time.Duration, bool) {
+	c.RLock()
+	defer c.RUnlock()
+
+	now := c.now()
+	item, ok := c.items[key]
+	if !ok || item.isExpired(now) {
+		return 0, false
+	}
+	return item.ttl(now), true
+}
+
 func (c *lfuCache) Remove(key interface{}) bool {
 	c.Lock()
-	defer c.Unlock()
+	defer c.unlockAndNotify()
 
-	return c.remove(key)
+	return c.remove(key, EvictionExplicit)
 }
 
-func (c *lfuCache) remove(key interface{}) bool {
+func (c *lfuCache) remove(key interface{}, reason EvictionReason) bool {
 	item, ok := c.items[key]
 	if !ok {
 		return false
 	}
 	heap.Remove(&c.heap, item.index)
 	delete(c.items, key)
+	c.forget(&item.baseItem)
+	c.evicted(key, item.value, reason)
 
-	if c.BeforeEvictedFunc != nil {
-		c.BeforeEvictedFunc(key, item.value)
-	}
-
-	return !item.isExpired()
+	return !item.isExpired(c.now())
 }
 
 func (c *lfuCache) Keys() []interface{} {
@@ -143,8 +175,9 @@ func (c *lfuCache) Keys() []interface{} {
 	defer c.RUnlock()
 
 	keys := make([]interface{}, 0)
+	now := c.now()
 	for k, v := range c.items {
-		if !v.isExpired() {
+		if !v.isExpired(now) {
 			keys = append(keys, k)
 		}
 	}
@@ -153,51 +186,145 @@ func (c *lfuCache) Keys() []interface{} {
 }
 
 func (c *lfuCache) CleanExpired() int {
+	return c.cleanExpired(func(key interface{}) {
+		c.remove(key, EvictionExpired)
+	})
+}
+
+func (c *lfuCache) Flush() {
 	c.Lock()
-	defer c.Unlock()
+	defer c.unlockAndNotify()
 
-	expiredKey := make([]interface{}, 0)
-	for k, v := range c.items {
-		if v.isExpired() {
-			expiredKey = append(expiredKey, k)
-		}
+	for k, item := range c.items {
+		c.evicted(k, item.value, EvictionFlushed)
 	}
+	c.Init()
+}
+
+func (c *lfuCache) drop() {
+	c.Lock()
+	defer c.Unlock()
+
+	c.stats.evicted(EvictionFlushed, len(c.items))
+	c.Init()
+}
 
-	for _, k := range expiredKey {
-		c.remove(k)
+func (c *lfuCache) Resize(n int) {
+	if n < 1 {
+		return
 	}
+	c.Lock()
+	defer c.unlockAndNotify()
 
-	return len(expiredKey)
+	c.size = n
+	c.trim()
 }
 
-func (c *lfuCache) Flush() {
+func (c *lfuCache) setLimit(n int) {
 	c.Lock()
-	defer c.Unlock()
+	defer c.unlockAndNotify()
 
-	c.Init()
+	c.limit = n
+	c.trim()
+}
+
+// trim evicts the items over the capacity or the max weight.
+func (c *lfuCache) trim() {
+	for c.overCapacity(len(c.items)) {
+		c.evict(1)
+	}
 }
 
 func (c *lfuCache) Len() int {
+	c.RLock()
+	defer c.RUnlock()
+
 	return len(c.items)
 }
 
+func (c *lfuCache) Stats() Stats {
+	c.RLock()
+	defer c.RUnlock()
+
+	return c.snapshotStats(len(c.items))
+}
+
 func (c *lfuCache) Has(key interface{}) bool {
 	c.RLock()
 	defer c.RUnlock()
 
 	item, ok := c.items[key]
-	return ok && !item.isExpired()
+	return ok && !item.isExpired(c.now())
+}
+
+func (c *lfuCache) SaveTo(w io.Writer) error {
+	return saveTo(c, w)
+}
+
+func (c *lfuCache) LoadFrom(r io.Reader) error {
+	return loadFrom(c, r)
+}
+
+func (c *lfuCache) snapshot() *snapshot {
+	c.RLock()
+	defer c.RUnlock()
+
+	items := make([]*lfuItem, 0, len(c.items))
+	now := c.now()
+	for _, item := range c.items {
+		if !item.isExpired(now) {
+			items = append(items, item)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return lfuLess(items[i], items[j])
+	})
+
+	s := &snapshot{Policy: c.policy, Entries: make([]snapshotEntry, 0, len(items))}
+	for _, item := range items {
+		e := newSnapshotEntry(&item.baseItem)
+		e.Freq = item.freq
+		s.Entries = append(s.Entries, e)
+	}
+	return s
+}
+
+func (c *lfuCache) restore(s *snapshot) {
+	c.Lock()
+	defer c.unlockAndNotify()
+
+	for _, e := range s.Entries {
+		c.set(e.Key, e.Value, NoExpiration)
+		item, ok := c.items[e.Key]
+		if !ok {
+			continue
+		}
+		item.expireAt(e.Expiration, &c.baseCache)
+		item.freq = e.accesses()
+		heap.Fix(&c.heap, item.index)
+	}
 }
 
 type lfuItem struct {
 	baseItem
 	index, freq int
+	// used is the tick of the last access.
+	used uint64
+}
+
+// lfuLess orders the items by the frequency, and the items of the same frequency from the least recently used,
+// so that the order is kept by a snapshot.
+func lfuLess(a, b *lfuItem) bool {
+	if a.freq != b.freq {
+		return a.freq < b.freq
+	}
+	return a.used < b.used
 }
 
 type lfuHeap []*lfuItem
 
 func (l lfuHeap) Len() int           { return len(l) }
-func (l lfuHeap) Less(i, j int) bool { return l[i].freq < l[j].freq }
+func (l lfuHeap) Less(i, j int) bool { return lfuLess(l[i], l[j]) }
 func (l lfuHeap) Swap(i, j int) {
 	l[i].index, l[j].index = j, i
 	l[i], l[j] = l[j], l[i]
@@ -210,6 +337,7 @@ func (l *lfuHeap) Push(x interface{}) {
 
 func (l *lfuHeap) Pop() interface{} {
 	x, n := (*l)[len(*l)-1], (*l)[:len(*l)-1]
+	(*l)[len(*l)-1] = nil
 	*l = n
 	return x
 }
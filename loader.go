@@ -0,0 +1,92 @@
+package gorsy_cache
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// errLoadPanicked is shared with the waiters of a load whose LoaderFunc panicked.
+var errLoadPanicked = errors.New("loader function panicked")
+
+// loadCall represents an in-flight or completed LoaderFunc invocation.
+type loadCall struct {
+	wg    sync.WaitGroup
+	value interface{}
+	err   error
+}
+
+// loadGroup collapses concurrent loads of the same key into a single LoaderFunc call.
+type loadGroup struct {
+	mu    sync.Mutex
+	calls map[interface{}]*loadCall
+}
+
+// do executes fn for key, making sure only one execution is in-flight for a given key at a time.
+// The goroutines arriving while fn is running wait for it and share its result.
+func (g *loadGroup) do(key interface{}, fn func() (interface{}, error)) (interface{}, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[interface{}]*loadCall)
+	}
+	if call, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		call.wg.Wait()
+		return call.value, call.err
+	}
+
+	call := &loadCall{err: errLoadPanicked}
+	call.wg.Add(1)
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		call.wg.Done()
+	}()
+
+	call.value, call.err = fn()
+	return call.value, call.err
+}
+
+// load fetches the value of a missing key by the LoaderFunc and stores it with the default expiration.
+// It must be called without holding the cache lock.
+// The get function is used to recheck the store under the lock, since the key may have been loaded by
+// a call which finished between the caller's miss and the start of this load.
+// The value is stored through the owner of the store if it has one, so that a value loaded while Migrate
+// swaps the store is stored into the new one instead of being dropped with the old.
+func (c *baseCache) load(
+	key interface{},
+	get func(key interface{}) (interface{}, error),
+	set func(key, value interface{}),
+) (interface{}, error) {
+	if c.LoaderFunc == nil {
+		return nil, &KeyNotFoundError{c.Name, key, nil}
+	}
+
+	return c.loads.do(key, func() (interface{}, error) {
+		// the recheck takes the lock of a retired store as well, as it isn't run again on the new store. A hit
+		// updates the access history of the old store, which is either taken by the snapshot or dropped with it.
+		c.RWMutex.Lock()
+		v, err := get(key)
+		c.RWMutex.Unlock()
+		if err == nil {
+			return v, nil
+		}
+
+		start := time.Now()
+		v, err = c.LoaderFunc(key)
+		c.stats.loaded(time.Since(start), err)
+		if err != nil {
+			return nil, &KeyNotFoundError{c.Name, key, err}
+		}
+		if c.owner != nil {
+			c.owner.Set(key, v)
+		} else {
+			set(key, v)
+		}
+		return v, nil
+	})
+}
@@ -2,6 +2,7 @@ package gorsy_cache
 
 import (
 	"container/list"
+	"io"
 	"time"
 )
 
@@ -26,93 +27,104 @@ func (c *lruCache) getBaseCache() *baseCache {
 }
 
 func (c *lruCache) Init() {
+	c.resetItems()
 	c.items = make(map[interface{}]*list.Element, c.size)
 	c.list = list.New()
 }
 
 func (c *lruCache) Get(key interface{}) (interface{}, error) {
 	c.Lock()
-	defer c.Unlock()
+	v, err := c.get(key)
+	c.Unlock()
+	if err == nil {
+		c.stats.hit()
+		return v, nil
+	}
 
-	return c.get(key, true)
+	c.stats.miss()
+	return c.load(key, c.get, c.Set)
 }
 
 func (c *lruCache) GetOnlyPresent(key interface{}) (interface{}, bool) {
 	c.Lock()
 	defer c.Unlock()
 
-	v, err := c.get(key, false)
+	v, err := c.get(key)
 	if err != nil {
+		c.stats.miss()
 		return nil, false
 	} else {
+		c.stats.hit()
 		return v, true
 	}
 }
 
-func (c *lruCache) get(key interface{}, fromLoader bool) (interface{}, error) {
+func (c *lruCache) get(key interface{}) (interface{}, error) {
 	item, ok := c.items[key]
-	if ok && !item.Value.(*lruItem).isExpired() {
-		c.list.PushBack(c.list.Remove(item))
+	if ok && !item.Value.(*lruItem).isExpired(c.now()) {
+		c.list.MoveToBack(item)
 		return item.Value.(*lruItem).value, nil
 	}
 
-	if fromLoader {
-		return c.getFromLoader(key)
-	} else {
-		return nil, &KeyNotFoundError{}
-	}
-}
-
-func (c *lruCache) getFromLoader(key interface{}) (interface{}, error) {
-	var v interface{}
-	var err error
-	if c.LoaderFunc != nil {
-		v, err = c.LoaderFunc(key)
-		if err != nil {
-			return nil, &KeyNotFoundError{c.Name, key, err}
-		}
-	}
-
-	return v, &KeyNotFoundError{c.Name, key, nil}
+	return nil, &KeyNotFoundError{c.Name, key, nil}
 }
 
 func (c *lruCache) Set(key, value interface{}) {
 	c.Lock()
-	defer c.Unlock()
+	defer c.unlockAndNotify()
 
 	c.set(key, value, DefaultExpiration)
 }
 
 func (c *lruCache) set(k, v interface{}, e time.Duration) {
+	w := c.weigh(k, v)
+	if c.tooHeavy(w) {
+		c.remove(k, EvictionCapacity)
+		c.reject(k, v)
+		return
+	}
+
 	ele, ok := c.items[k]
 	if ok {
-		ele.Value.(*lruItem).value = v
-		ele.Value.(*lruItem).setExpiration(e, &c.baseCache)
-		c.list.PushBack(e)
+		item := ele.Value.(*lruItem)
+		old := item.value
+		item.value = v
+		item.setExpiration(e, &c.baseCache)
+		c.setWeight(&item.baseItem, w)
+		c.list.MoveToBack(ele)
+		c.evicted(k, old, EvictionReplaced)
+		for c.overWeight(0) {
+			c.evict(1)
+		}
 		return
 	}
 
-	if len(c.items) == c.size {
+	for len(c.items) > 0 && len(c.items) >= c.capacity() {
+		c.evict(1)
+	}
+	for c.overWeight(w) {
 		c.evict(1)
 	}
 
-	item := &lruItem{baseItem{k, v, nil}}
+	item := &lruItem{newBaseItem(k, v)}
 	item.setExpiration(e, &c.baseCache)
+	c.setWeight(&item.baseItem, w)
 	c.items[k] = c.list.PushBack(item)
 }
 
 func (c *lruCache) SetWithExpire(k, v interface{}, e time.Duration) {
 	c.Lock()
-	defer c.Unlock()
+	defer c.unlockAndNotify()
 
 	c.set(k, v, e)
 }
 
 func (c *lruCache) evict(size int) {
 	for i := 0; i < size; i++ {
-		e := c.list.Front()
-		delete(c.items, e.Value.(*lruItem).key)
-		c.list.Remove(e)
+		item := c.list.Remove(c.list.Front()).(*lruItem)
+		delete(c.items, item.key)
+		c.forget(&item.baseItem)
+		c.evicted(item.key, item.value, EvictionCapacity)
 	}
 }
 
@@ -121,29 +133,39 @@ func (c *lruCache) Has(key interface{}) bool {
 	defer c.RUnlock()
 
 	item, ok := c.items[key]
-	return ok && !item.Value.(*lruItem).isExpired()
+	return ok && !item.Value.(*lruItem).isExpired(c.now())
+}
+
+func (c *lruCache) TTL(key interface{}) (time.Duration, bool) {
+	c.RLock()
+	defer c.RUnlock()
+
+	now := c.now()
+	ele, ok := c.items[key]
+	if !ok || ele.Value.(*lruItem).isExpired(now) {
+		return 0, false
+	}
+	return ele.Value.(*lruItem).ttl(now), true
 }
 
 func (c *lruCache) Remove(key interface{}) bool {
 	c.Lock()
-	defer c.Unlock()
+	defer c.unlockAndNotify()
 
-	return c.remove(key)
+	return c.remove(key, EvictionExplicit)
 }
 
-func (c *lruCache) remove(key interface{}) bool {
+func (c *lruCache) remove(key interface{}, reason EvictionReason) bool {
 	item, ok := c.items[key]
 	if !ok {
 		return false
 	}
 	delete(c.items, key)
+	c.forget(&item.Value.(*lruItem).baseItem)
 	c.list.Remove(item)
+	c.evicted(key, item.Value.(*lruItem).value, reason)
 
-	if c.BeforeEvictedFunc != nil {
-		c.BeforeEvictedFunc(key, item.Value.(*lruItem).value)
-	}
-
-	return !item.Value.(*lruItem).isExpired()
+	return !item.Value.(*lruItem).isExpired(c.now())
 }
 
 func (c *lruCache) Keys() []interface{} {
@@ -151,8 +173,9 @@ func (c *lruCache) Keys() []interface{} {
 	defer c.RUnlock()
 
 	keys := make([]interface{}, 0)
+	now := c.now()
 	for k, v := range c.items {
-		if !v.Value.(*lruItem).isExpired() {
+		if !v.Value.(*lruItem).isExpired(now) {
 			keys = append(keys, k)
 		}
 	}
@@ -161,34 +184,105 @@ func (c *lruCache) Keys() []interface{} {
 }
 
 func (c *lruCache) CleanExpired() int {
+	return c.cleanExpired(func(key interface{}) {
+		c.remove(key, EvictionExpired)
+	})
+}
+
+func (c *lruCache) Flush() {
 	c.Lock()
-	defer c.Unlock()
+	defer c.unlockAndNotify()
 
-	expiredKey := make([]interface{}, 0)
-	for k, v := range c.items {
-		if v.Value.(*lruItem).isExpired() {
-			expiredKey = append(expiredKey, k)
-		}
+	for e := c.list.Front(); e != nil; e = e.Next() {
+		item := e.Value.(*lruItem)
+		c.evicted(item.key, item.value, EvictionFlushed)
 	}
+	c.Init()
+}
+
+func (c *lruCache) drop() {
+	c.Lock()
+	defer c.Unlock()
 
-	for _, k := range expiredKey {
-		c.remove(k)
+	c.stats.evicted(EvictionFlushed, len(c.items))
+	c.Init()
+}
+
+func (c *lruCache) Resize(n int) {
+	if n < 1 {
+		return
 	}
+	c.Lock()
+	defer c.unlockAndNotify()
 
-	return len(expiredKey)
+	c.size = n
+	c.trim()
 }
 
-func (c *lruCache) Flush() {
+func (c *lruCache) setLimit(n int) {
 	c.Lock()
-	defer c.Unlock()
+	defer c.unlockAndNotify()
 
-	c.Init()
+	c.limit = n
+	c.trim()
+}
+
+// trim evicts the items over the capacity or the max weight.
+func (c *lruCache) trim() {
+	for c.overCapacity(len(c.items)) {
+		c.evict(1)
+	}
 }
 
 func (c *lruCache) Len() int {
+	c.RLock()
+	defer c.RUnlock()
+
 	return len(c.items)
 }
 
+func (c *lruCache) Stats() Stats {
+	c.RLock()
+	defer c.RUnlock()
+
+	return c.snapshotStats(len(c.items))
+}
+
+func (c *lruCache) SaveTo(w io.Writer) error {
+	return saveTo(c, w)
+}
+
+func (c *lruCache) LoadFrom(r io.Reader) error {
+	return loadFrom(c, r)
+}
+
+func (c *lruCache) snapshot() *snapshot {
+	c.RLock()
+	defer c.RUnlock()
+
+	s := &snapshot{Policy: c.policy, Entries: make([]snapshotEntry, 0, len(c.items))}
+	now := c.now()
+	for e := c.list.Front(); e != nil; e = e.Next() {
+		item := e.Value.(*lruItem)
+		if !item.isExpired(now) {
+			s.Entries = append(s.Entries, newSnapshotEntry(&item.baseItem))
+		}
+	}
+	return s
+}
+
+func (c *lruCache) restore(s *snapshot) {
+	c.Lock()
+	defer c.unlockAndNotify()
+
+	for _, e := range s.Entries {
+		c.set(e.Key, e.Value, NoExpiration)
+		if ele, ok := c.items[e.Key]; ok {
+			ele.Value.(*lruItem).expireAt(e.Expiration, &c.baseCache)
+		}
+	}
+}
+
 type lruItem struct {
 	baseItem
 }
@@ -0,0 +1,205 @@
+package gorsy_cache
+
+import (
+	"container/list"
+	"reflect"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// The per-entry overhead of the stores, besides the items themselves.
+const (
+	// mapEntryOverhead approximates a slot of a map from interface{} to a pointer, with the load factor of the map.
+	mapEntryOverhead = 32
+	// mapHeaderSize approximates the header of a map value.
+	mapHeaderSize = 48
+	// expirationOverhead is the expiration of a item and its slot in the expiration index.
+	expirationOverhead = int64(unsafe.Sizeof(time.Time{}) + unsafe.Sizeof(uintptr(0)))
+)
+
+// entryOverheads are the bytes taken by the bookkeeping of a entry of the built-in policies.
+var entryOverheads = map[string]int64{
+	SIMPLE: int64(unsafe.Sizeof(simpleItem{})) + mapEntryOverhead,
+	LRU:    int64(unsafe.Sizeof(lruItem{})+unsafe.Sizeof(list.Element{})) + mapEntryOverhead,
+	LFU:    int64(unsafe.Sizeof(lfuItem{})+unsafe.Sizeof(uintptr(0))) + mapEntryOverhead,
+	// a arc item lives in t1 or t2, their index and the map of the items. The ghost lists keep up to as many
+	// evicted items as the live ones, each with its key, a list element and a slot in the index of the list.
+	ARC: int64(2*unsafe.Sizeof(arcItem{})+2*unsafe.Sizeof(list.Element{})) + 3*mapEntryOverhead,
+	TINYLFU: int64(unsafe.Sizeof(policyItem{})+unsafe.Sizeof(tinyLFUEntry{})+unsafe.Sizeof(list.Element{})) +
+		2*mapEntryOverhead,
+}
+
+// entryOverhead returns the bytes taken by the bookkeeping of a entry of the policy.
+// The policies registered by RegisterPolicy are only counted for the item kept by the store.
+func entryOverhead(policy string) int64 {
+	if n, ok := entryOverheads[policy]; ok {
+		return n + expirationOverhead
+	}
+	return int64(unsafe.Sizeof(policyItem{})) + mapEntryOverhead + expirationOverhead
+}
+
+// MemoryWeigher returns a Weigher estimating the bytes of heap taken by a entry of a cache of the policy,
+// which is the size of the key and the value by SizeOf plus the bookkeeping of the policy.
+// The key is counted twice for ARC, as a ghost of the same size may be kept for every entry.
+func MemoryWeigher(policy string) Weigher {
+	overhead := entryOverhead(policy)
+	keys := int64(1)
+	if policy == ARC {
+		keys = 2
+	}
+	return func(key, value interface{}) int64 {
+		return keys*SizeOf(key) + SizeOf(value) + overhead
+	}
+}
+
+// SizeOf estimates the bytes of heap taken by v stored in a interface{}, including the memory it references
+// through strings, slices, maps and pointers. The types are inspected by reflection once, and the values of the
+// types without references, such as the structs of numbers, are sized without walking them.
+// A value reachable several times through pointers is counted once, the channels and functions are counted
+// only by their headers.
+func SizeOf(v interface{}) int64 {
+	if v == nil {
+		return 0
+	}
+	rv := reflect.ValueOf(v)
+
+	var n int64
+	if !isPointerShaped(rv.Type()) {
+		// the value is boxed by the interface.
+		n = int64(rv.Type().Size())
+	}
+	var s sizer
+	return n + s.referenced(rv)
+}
+
+// sizer walks the memory referenced by a value, seen keeps the pointers walked to count them once.
+type sizer struct {
+	seen map[uintptr]bool
+}
+
+// referenced returns the bytes referenced by v, not including the size of v itself.
+func (s *sizer) referenced(v reflect.Value) int64 {
+	t := v.Type()
+	if typeInfoOf(t).flat {
+		return 0
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return int64(v.Len())
+	case reflect.Ptr:
+		if v.IsNil() || !s.visit(v.Pointer()) {
+			return 0
+		}
+		return int64(t.Elem().Size()) + s.referenced(v.Elem())
+	case reflect.Interface:
+		if v.IsNil() {
+			return 0
+		}
+		e := v.Elem()
+		if isPointerShaped(e.Type()) {
+			return s.referenced(e)
+		}
+		return int64(e.Type().Size()) + s.referenced(e)
+	case reflect.Slice:
+		if v.IsNil() || !s.visit(v.Pointer()) {
+			return 0
+		}
+		n := int64(v.Cap()) * int64(t.Elem().Size())
+		if !typeInfoOf(t.Elem()).flat {
+			for i := 0; i < v.Len(); i++ {
+				n += s.referenced(v.Index(i))
+			}
+		}
+		return n
+	case reflect.Array:
+		var n int64
+		for i := 0; i < v.Len(); i++ {
+			n += s.referenced(v.Index(i))
+		}
+		return n
+	case reflect.Struct:
+		var n int64
+		for i := 0; i < v.NumField(); i++ {
+			n += s.referenced(v.Field(i))
+		}
+		return n
+	case reflect.Map:
+		if v.IsNil() || !s.visit(v.Pointer()) {
+			return 0
+		}
+		// the slots are allocated by groups of 8, filled up to 7/8.
+		slots := (int64(v.Len())*8/7 + 7) &^ 7
+		n := mapHeaderSize + slots*int64(t.Key().Size()+t.Elem().Size()+1)
+		if !typeInfoOf(t.Key()).flat || !typeInfoOf(t.Elem()).flat {
+			iter := v.MapRange()
+			for iter.Next() {
+				n += s.referenced(iter.Key()) + s.referenced(iter.Value())
+			}
+		}
+		return n
+	default:
+		return 0
+	}
+}
+
+// visit reports whether the pointer is walked for the first time.
+func (s *sizer) visit(p uintptr) bool {
+	if s.seen == nil {
+		s.seen = make(map[uintptr]bool)
+	}
+	if s.seen[p] {
+		return false
+	}
+	s.seen[p] = true
+	return true
+}
+
+// typeInfo is what SizeOf learns of a type.
+type typeInfo struct {
+	// flat types reference no memory, their values take exactly the size of the type.
+	flat bool
+}
+
+// typeInfos caches the typeInfo of the types sized by SizeOf.
+var typeInfos sync.Map
+
+func typeInfoOf(t reflect.Type) typeInfo {
+	if info, ok := typeInfos.Load(t); ok {
+		return info.(typeInfo)
+	}
+	info := typeInfo{flat: isFlat(t)}
+	typeInfos.Store(t, info)
+	return info
+}
+
+func isFlat(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return true
+	case reflect.Array:
+		return t.Len() == 0 || isFlat(t.Elem())
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if !isFlat(t.Field(i).Type) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// isPointerShaped reports whether the values of t are stored in a interface{} directly, without being boxed.
+func isPointerShaped(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,149 @@
+package gorsy_cache_test
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	gorsy_cache "github.com/arianxx/gorsy-cache"
+)
+
+type point struct {
+	X, Y  int64
+	Z     int32
+	Valid bool
+}
+
+type node struct {
+	Name string
+	Next *node
+}
+
+func TestSizeOf(t *testing.T) {
+	if strconv.IntSize != 64 {
+		t.Skip("the sizes are of a 64-bit platform")
+	}
+	shared := &point{}
+	loop := &node{Name: "loop"}
+	loop.Next = loop
+
+	for _, tt := range []struct {
+		name string
+		v    interface{}
+		want int64
+	}{
+		{"nil", nil, 0},
+		{"int", int64(7), 8},
+		{"string", "hello", 16 + 5},
+		{"empty string", "", 16},
+		{"bytes", make([]byte, 10, 16), 24 + 16},
+		{"nil bytes", []byte(nil), 24},
+		{"struct", point{}, 24},
+		{"pointer", &point{}, 24},
+		{"shared pointer", [2]*point{shared, shared}, 16 + 24},
+		{"cycle", loop, 24 + 4},
+		{"strings", []string{"ab", "cde"}, 24 + 2*16 + 5},
+		// 3 entries take a group of 8 slots of a key, a value and a control byte.
+		{"map", map[int64]int64{1: 1, 2: 2, 3: 3}, 48 + 8*(8+8+1)},
+		{"map of strings", map[string]string{"a": "bc"}, 48 + 8*(16+16+1) + 3},
+		{"struct of strings", struct{ A, B string }{"a", "bc"}, 32 + 3},
+	} {
+		if got := gorsy_cache.SizeOf(tt.v); got != tt.want {
+			t.Errorf("SizeOf(%s) = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMemoryWeigher(t *testing.T) {
+	key, value := "key", []byte("value")
+	base := gorsy_cache.SizeOf(key) + gorsy_cache.SizeOf(value)
+	for _, policy := range []string{gorsy_cache.SIMPLE, gorsy_cache.LRU, gorsy_cache.LFU, gorsy_cache.TINYLFU} {
+		if w := gorsy_cache.MemoryWeigher(policy)(key, value); w <= base {
+			t.Errorf("MemoryWeigher(%s) = %d, want more than the %d bytes of the entry", policy, w, base)
+		}
+	}
+	// the ghost of a arc entry keeps a key and its bookkeeping.
+	lru := gorsy_cache.MemoryWeigher(gorsy_cache.LRU)(key, value)
+	if w := gorsy_cache.MemoryWeigher(gorsy_cache.ARC)(key, value); w < lru+gorsy_cache.SizeOf(key) {
+		t.Errorf("MemoryWeigher(arc) = %d, want at least %d", w, lru+gorsy_cache.SizeOf(key))
+	}
+}
+
+// heapAlloc returns the bytes of heap in use after a collection.
+func heapAlloc() int64 {
+	runtime.GC()
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return int64(m.HeapAlloc)
+}
+
+// within reports whether the estimate is within a third of the bytes measured.
+func within(estimate, measured int64) bool {
+	return estimate*3 >= measured*2 && estimate*3 <= measured*4
+}
+
+// TestSizeOfHeap compares SizeOf with the heap taken by many values of each kind.
+func TestSizeOfHeap(t *testing.T) {
+	const n = 20000
+	for _, tt := range []struct {
+		name string
+		make func(i int) interface{}
+	}{
+		{"string", func(i int) interface{} { return fmt.Sprintf("%032d", i) }},
+		{"bytes", func(i int) interface{} { return make([]byte, 100) }},
+		{"struct", func(i int) interface{} { return point{X: int64(i)} }},
+		{"map", func(i int) interface{} {
+			m := make(map[int]int)
+			for j := 0; j < 5; j++ {
+				m[i+j] = j
+			}
+			return m
+		}},
+		{"slice", func(i int) interface{} { return []int64{int64(i), 1, 2, 3, 4, 5, 6, 7} }},
+	} {
+		vs := make([]interface{}, n)
+		before := heapAlloc()
+		for i := range vs {
+			vs[i] = tt.make(i)
+		}
+		measured := heapAlloc() - before
+
+		var estimate int64
+		for _, v := range vs {
+			estimate += gorsy_cache.SizeOf(v)
+		}
+		if !within(estimate, measured) {
+			t.Errorf("SizeOf of %d %s values is %d bytes, the heap grew by %d", n, tt.name, estimate, measured)
+		}
+		runtime.KeepAlive(vs)
+	}
+}
+
+// TestMemoryWeigherHeap fills a cache of each policy twice over, so that the arc ghost lists are full as well,
+// and compares the weight of the entries with the heap taken by the cache.
+func TestMemoryWeigherHeap(t *testing.T) {
+	const n = 20000
+	for _, policy := range []string{gorsy_cache.SIMPLE, gorsy_cache.LRU, gorsy_cache.LFU, gorsy_cache.ARC, gorsy_cache.TINYLFU} {
+		before := heapAlloc()
+		b, err := gorsy_cache.NewBuilder(policy, n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := b.SetPurgeInterval(gorsy_cache.NoPurge).SetMaxMemory(1 << 40).Build()
+		for i := 0; i < 2*n; i++ {
+			k := fmt.Sprintf("key-%027d", i)
+			c.Set(k, strings.Repeat("v", 64))
+			// a hit moves the arc entry to t2, so that its eviction leaves a ghost.
+			c.Get(k)
+		}
+		measured := heapAlloc() - before
+		weight := c.Stats().Weight
+
+		if !within(weight, measured) {
+			t.Errorf("%s: the weight of the entries is %d bytes, the heap grew by %d", policy, weight, measured)
+		}
+		c.Close()
+	}
+}
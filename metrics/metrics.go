@@ -0,0 +1,169 @@
+// Package metrics exposes the statistics of the gorsy cache stores registered to it in the Prometheus text format.
+//
+//	metrics.Register(cache)
+//	http.Handle("/metrics", metrics.Handler())
+//
+// Every series is labelled with the name of the cache store and the name of its policy. The cache stores sharing
+// a name are told apart by a cache_instance label, numbered in the order they were registered.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/arianxx/gorsy-cache"
+)
+
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// registered are the cache stores exposed by Handler, with the order they were registered in.
+var registered = struct {
+	sync.Mutex
+	caches map[gorsy_cache.Cache]int64
+	seq    int64
+}{caches: make(map[gorsy_cache.Cache]int64)}
+
+// Register exposes the statistics of c by Handler. The cache store is held until it is unregistered,
+// so it has to be unregistered once closed.
+func Register(c gorsy_cache.Cache) {
+	registered.Lock()
+	defer registered.Unlock()
+
+	if _, ok := registered.caches[c]; !ok {
+		registered.seq++
+		registered.caches[c] = registered.seq
+	}
+}
+
+// Unregister stops exposing the statistics of c.
+func Unregister(c gorsy_cache.Cache) {
+	registered.Lock()
+	defer registered.Unlock()
+
+	delete(registered.caches, c)
+}
+
+// Caches returns the cache stores registered, in the order they were registered in.
+func Caches() []gorsy_cache.Cache {
+	registered.Lock()
+	defer registered.Unlock()
+
+	caches := make([]gorsy_cache.Cache, 0, len(registered.caches))
+	for c := range registered.caches {
+		caches = append(caches, c)
+	}
+	sort.Slice(caches, func(i, j int) bool {
+		return registered.caches[caches[i]] < registered.caches[caches[j]]
+	})
+	return caches
+}
+
+// Handler returns a http.Handler exposing the statistics of the cache stores registered.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		_ = Write(w, Caches())
+	})
+}
+
+// metric describes a family of series and how to read its value from a cache store.
+type metric struct {
+	name, help, typ string
+	// label is a extra label pair of the series, such as `reason="expired"`.
+	label string
+	value func(s gorsy_cache.Stats, c gorsy_cache.Cache) float64
+}
+
+var metrics = []metric{
+	{"gorsy_cache_hits_total", "Number of lookups finding a present item.", "counter", "",
+		func(s gorsy_cache.Stats, _ gorsy_cache.Cache) float64 { return float64(s.Hits) }},
+	{"gorsy_cache_misses_total", "Number of lookups finding no item.", "counter", "",
+		func(s gorsy_cache.Stats, _ gorsy_cache.Cache) float64 { return float64(s.Misses) }},
+	{"gorsy_cache_loads_total", "Number of loader function calls.", "counter", `result="success"`,
+		func(s gorsy_cache.Stats, _ gorsy_cache.Cache) float64 { return float64(s.LoadSuccesses) }},
+	{"gorsy_cache_loads_total", "", "", `result="failure"`,
+		func(s gorsy_cache.Stats, _ gorsy_cache.Cache) float64 { return float64(s.LoadFailures) }},
+	{"gorsy_cache_load_seconds_total", "Time spent in the loader function.", "counter", "",
+		func(s gorsy_cache.Stats, _ gorsy_cache.Cache) float64 { return s.LoadTime.Seconds() }},
+	{"gorsy_cache_evictions_total", "Number of items left the cache store.", "counter", `reason="capacity"`,
+		func(s gorsy_cache.Stats, _ gorsy_cache.Cache) float64 { return float64(s.CapacityEvictions) }},
+	{"gorsy_cache_evictions_total", "", "", `reason="expired"`,
+		func(s gorsy_cache.Stats, _ gorsy_cache.Cache) float64 { return float64(s.ExpiredEvictions) }},
+	{"gorsy_cache_evictions_total", "", "", `reason="explicit"`,
+		func(s gorsy_cache.Stats, _ gorsy_cache.Cache) float64 { return float64(s.ExplicitEvictions) }},
+	{"gorsy_cache_evictions_total", "", "", `reason="replaced"`,
+		func(s gorsy_cache.Stats, _ gorsy_cache.Cache) float64 { return float64(s.ReplacedEvictions) }},
+	{"gorsy_cache_evictions_total", "", "", `reason="flushed"`,
+		func(s gorsy_cache.Stats, _ gorsy_cache.Cache) float64 { return float64(s.FlushedEvictions) }},
+	{"gorsy_cache_entries", "Number of items in the cache store.", "gauge", "",
+		func(_ gorsy_cache.Stats, c gorsy_cache.Cache) float64 { return float64(c.Len()) }},
+	{"gorsy_cache_weight", "Total weight of the items in the cache store.", "gauge", "",
+		func(s gorsy_cache.Stats, _ gorsy_cache.Cache) float64 { return float64(s.Weight) }},
+	{"gorsy_cache_capacity", "Configured size of the cache store.", "gauge", "",
+		func(s gorsy_cache.Stats, _ gorsy_cache.Cache) float64 { return float64(s.Capacity) }},
+}
+
+// Write writes the statistics of the caches to w in the Prometheus text format. The caches sharing a name are
+// labelled with their cache_instance, numbered from 1 in the order of caches, and counted by a series of their own.
+func Write(w io.Writer, caches []gorsy_cache.Cache) error {
+	stats := make([]gorsy_cache.Stats, len(caches))
+	for i, c := range caches {
+		stats[i] = c.Stats()
+	}
+	order := make([]int, len(caches))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return stats[order[i]].Name < stats[order[j]].Name
+	})
+
+	labels := make([]string, len(caches))
+	duplicates := 0
+	for k := 0; k < len(order); {
+		name := stats[order[k]].Name
+		end := k + 1
+		for end < len(order) && stats[order[end]].Name == name {
+			end++
+		}
+		for n, i := range order[k:end] {
+			labels[i] = fmt.Sprintf(`cache="%s",policy="%s"`, escape(name), escape(stats[i].Policy))
+			if end-k > 1 {
+				labels[i] += fmt.Sprintf(`,cache_instance="%d"`, n+1)
+			}
+		}
+		duplicates += end - k - 1
+		k = end
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# HELP gorsy_cache_duplicate_names Number of cache stores named as another one before them.\n")
+	fmt.Fprintf(bw, "# TYPE gorsy_cache_duplicate_names gauge\n")
+	fmt.Fprintf(bw, "gorsy_cache_duplicate_names %d\n", duplicates)
+	for _, m := range metrics {
+		if m.help != "" {
+			fmt.Fprintf(bw, "# HELP %s %s\n", m.name, m.help)
+			fmt.Fprintf(bw, "# TYPE %s %s\n", m.name, m.typ)
+		}
+		for _, i := range order {
+			l := labels[i]
+			if m.label != "" {
+				l += "," + m.label
+			}
+			fmt.Fprintf(bw, "%s{%s} %g\n", m.name, l, m.value(stats[i], caches[i]))
+		}
+	}
+	return bw.Flush()
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escape escapes a label value as required by the text format.
+func escape(s string) string {
+	return escaper.Replace(s)
+}
@@ -0,0 +1,91 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/arianxx/gorsy-cache"
+)
+
+func build(t *testing.T, name string) gorsy_cache.Cache {
+	t.Helper()
+	b, err := gorsy_cache.NewBuilder(gorsy_cache.LRU, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := b.SetName(name).SetPurgeInterval(gorsy_cache.NoPurge).Build()
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestWrite(t *testing.T) {
+	a, b := build(t, "a"), build(t, `b"`)
+	a.Set("key", "value")
+	a.Get("key")
+
+	var out strings.Builder
+	if err := Write(&out, []gorsy_cache.Cache{b, a}); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"# TYPE gorsy_cache_hits_total counter\n" +
+			`gorsy_cache_hits_total{cache="a",policy="lru"} 1` + "\n" +
+			`gorsy_cache_hits_total{cache="b\"",policy="lru"} 0` + "\n",
+		`gorsy_cache_entries{cache="a",policy="lru"} 1` + "\n",
+		`gorsy_cache_evictions_total{cache="a",policy="lru",reason="expired"} 0` + "\n",
+	} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("the output has no %q:\n%s", line, out.String())
+		}
+	}
+}
+
+// TestWriteDuplicateNames tells apart the caches sharing a name, the other caches are written as usual.
+func TestWriteDuplicateNames(t *testing.T) {
+	a, b, other := build(t, "same"), build(t, "same"), build(t, "other")
+	b.Set("key", "value")
+
+	var out strings.Builder
+	if err := Write(&out, []gorsy_cache.Cache{a, other, b}); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"gorsy_cache_duplicate_names 1\n",
+		`gorsy_cache_entries{cache="other",policy="lru"} 0` + "\n",
+		`gorsy_cache_entries{cache="same",policy="lru",cache_instance="1"} 0` + "\n",
+		`gorsy_cache_entries{cache="same",policy="lru",cache_instance="2"} 1` + "\n",
+	} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("the output has no %q:\n%s", line, out.String())
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	a, b := build(t, "registered"), build(t, "not registered")
+	Register(a)
+	Register(a)
+	defer Unregister(a)
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != contentType {
+		t.Fatalf("Handler answered %d of %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if strings.Count(body, `gorsy_cache_entries{cache="registered"`) != 1 {
+		t.Errorf("the registered cache is not written once:\n%s", body)
+	}
+	if strings.Contains(body, `cache="not registered"`) {
+		t.Errorf("the cache not registered is written:\n%s", body)
+	}
+
+	Register(b)
+	Unregister(a)
+	if caches := Caches(); len(caches) != 1 || caches[0] != b {
+		t.Errorf("Caches() = %v after Unregister, want the other cache", caches)
+	}
+	Unregister(b)
+}
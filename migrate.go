@@ -0,0 +1,86 @@
+package gorsy_cache
+
+import (
+	"errors"
+	"fmt"
+)
+
+// errMigrateStore is returned by the Migrate of a store, whose policy can't be swapped in place.
+var errMigrateStore = errors.New("only the cache returned by Build can migrate to another policy")
+
+// Migrate of a store is unsupported, the store is swapped by the Cache returned by Build.
+func (c *baseCache) Migrate(to string) error {
+	return errMigrateStore
+}
+
+// Migrate swaps the store for a new one of the policy `to` with the same setups, sharded alike. The live
+// entries are carried over with their expirations and the access history exported by the old policy,
+// such as the lru order, the lfu frequencies and the arc lists, so the cache stays warm.
+//
+// The writes, and the reads updating the access history such as Get, wait for the migration,
+// the other reads go on from the old store. The expired entries are collected
+// before, and the statistics start over with the new store. If the restore into the new store panics, the error
+// is returned and the cache goes on with the old store.
+func (h *handle) Migrate(to string) error {
+	if h.isClosed() {
+		return ErrClosed
+	}
+	h.CleanExpired()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.isClosed() {
+		return ErrClosed
+	}
+	ref := h.ref()
+	old := ref.c
+	bc := old.getBaseCache()
+	if to == bc.policy {
+		return nil
+	}
+
+	b, err := NewBuilder(to, bc.size)
+	if err != nil {
+		return err
+	}
+	b.bc.inherit(bc)
+	b.bc.policy = to
+	if sc, ok := old.(*shardedCache); ok {
+		b.shards = len(sc.shards)
+	}
+	if bc.memoryWeigher {
+		b.bc.weigher = MemoryWeigher(to)
+	}
+	c := b.store()
+
+	// the writes from now on wait for the swap and go to the new store, the old one is left to the gc
+	old.setRetired(true)
+	if err := restoreInto(c, old.snapshot(), bc.limit); err != nil {
+		// the old store is used again, the writes waiting for the swap are run on it
+		old.setRetired(false)
+		h.c.Store(newStoreRef(old))
+		close(ref.swapped)
+		return err
+	}
+
+	h.c.Store(newStoreRef(c))
+	close(ref.swapped)
+	return nil
+}
+
+// restoreInto restores the snapshot into the new store of a migration, reporting a panic of it as a error,
+// such as of a weigher or a callback.
+func restoreInto(c Cache, s *snapshot, limit int) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("migrate to %s: %v", c.getBaseCache().policy, r)
+		}
+	}()
+
+	c.restore(s)
+	if limit > 0 {
+		c.setLimit(limit)
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package gorsy_cache_test
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	gorsy_cache "github.com/arianxx/gorsy-cache"
+)
+
+// TestMigrateWithWriters migrates a cache written by many goroutines, whose callback writes to the cache as well.
+func TestMigrateWithWriters(t *testing.T) {
+	var c gorsy_cache.Cache
+	b, err := gorsy_cache.NewBuilder(gorsy_cache.LRU, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c = b.
+		SetPurgeInterval(gorsy_cache.NoPurge).
+		SetEvictionDelivery(gorsy_cache.DeliverAfterUnlock).
+		SetEvictedFunc(func(key, value interface{}, reason gorsy_cache.EvictionReason) {
+			if k, ok := key.(int); ok && reason == gorsy_cache.EvictionCapacity && k > 0 && k%10 == 0 {
+				c.Set(-k, value)
+			}
+		}).
+		Build()
+	defer c.Close()
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for n := i; ; n += 16 {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				c.Set(n, n)
+			}
+		}(i)
+	}
+	defer func() {
+		close(stop)
+		wg.Wait()
+	}()
+
+	done := make(chan error, 1)
+	go func() { done <- c.Migrate(gorsy_cache.ARC) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Migrate is starved by the writers")
+	}
+}
+
+// TestMigrateDuringLoad swaps the store while a loader is running, the loaded value goes to the new store.
+func TestMigrateDuringLoad(t *testing.T) {
+	loading, release := make(chan struct{}), make(chan struct{})
+	b, err := gorsy_cache.NewBuilder(gorsy_cache.LRU, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := b.
+		SetPurgeInterval(gorsy_cache.NoPurge).
+		SetLoaderFunc(func(key interface{}) (interface{}, error) {
+			close(loading)
+			<-release
+			return "loaded", nil
+		}).
+		Build()
+	defer c.Close()
+
+	got := make(chan interface{}, 1)
+	go func() {
+		v, _ := c.Get("key")
+		got <- v
+	}()
+	<-loading
+	if err := c.Migrate(gorsy_cache.LFU); err != nil {
+		t.Fatal(err)
+	}
+	close(release)
+
+	if v := <-got; v != "loaded" {
+		t.Fatalf("Get returned %v, want loaded", v)
+	}
+	if v, ok := c.GetOnlyPresent("key"); !ok || v != "loaded" {
+		t.Fatalf("the loaded value is lost by the migration: %v, %v", v, ok)
+	}
+}
+
+// TestMigrateKeepsWrites migrates a sharded cache back and forth while it is written, none of the writes is lost.
+func TestMigrateKeepsWrites(t *testing.T) {
+	const writers, keys = 8, 2000
+	b, err := gorsy_cache.NewShardedBuilder(gorsy_cache.LRU, 2*writers*keys, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := b.SetPurgeInterval(gorsy_cache.NoPurge).SetDefaultExpiration(gorsy_cache.NoExpiration).Build()
+	defer c.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for n := 0; n < keys; n++ {
+				c.Set(i*keys+n, n)
+			}
+		}(i)
+	}
+	for _, policy := range []string{gorsy_cache.ARC, gorsy_cache.LFU, gorsy_cache.LRU} {
+		if err := c.Migrate(policy); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+
+	if n := c.Len(); n != writers*keys {
+		t.Errorf("Len() = %d after the migrations, want %d", n, writers*keys)
+	}
+}
+
+// TestMigrateRestoreFails panics in the weigher of the new store while a write waits for the swap,
+// the cache goes on with the old store and the write is done on it.
+func TestMigrateRestoreFails(t *testing.T) {
+	var failing int32
+	restoring, release := make(chan struct{}), make(chan struct{})
+	b, err := gorsy_cache.NewBuilder(gorsy_cache.LRU, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := b.
+		SetPurgeInterval(gorsy_cache.NoPurge).
+		SetWeigher(func(key, value interface{}) int64 {
+			if atomic.CompareAndSwapInt32(&failing, 1, 0) {
+				close(restoring)
+				<-release
+				panic("weigher failed")
+			}
+			return 1
+		}).
+		Build()
+	defer c.Close()
+	for i := 0; i < 10; i++ {
+		c.Set(i, i)
+	}
+
+	atomic.StoreInt32(&failing, 1)
+	migrated := make(chan error, 1)
+	go func() { migrated <- c.Migrate(gorsy_cache.ARC) }()
+	<-restoring
+	written := make(chan struct{})
+	go func() {
+		c.Set("key", "value")
+		close(written)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+
+	if err := <-migrated; err == nil {
+		t.Fatal("Migrate succeeded with a panicking weigher")
+	}
+	select {
+	case <-written:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the write waiting for the swap hangs after the failed migration")
+	}
+	if p := c.Stats().Policy; p != gorsy_cache.LRU {
+		t.Errorf("policy %s after the failed migration, want lru", p)
+	}
+	if n := c.Len(); n != 11 {
+		t.Errorf("Len() = %d after the failed migration, want 11", n)
+	}
+	if v, ok := c.GetOnlyPresent("key"); !ok || v != "value" {
+		t.Errorf("the write is lost: %v, %v", v, ok)
+	}
+
+	if err := c.Migrate(gorsy_cache.ARC); err != nil {
+		t.Fatal(err)
+	}
+	if n := c.Len(); n != 11 {
+		t.Errorf("Len() = %d after the migration, want 11", n)
+	}
+}
+
+// TestMigrateMemoryWeigher weighs the entries by the MemoryWeigher of the new policy after a migration.
+func TestMigrateMemoryWeigher(t *testing.T) {
+	b, err := gorsy_cache.NewBuilder(gorsy_cache.LRU, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := b.SetPurgeInterval(gorsy_cache.NoPurge).SetMaxMemory(1 << 20).Build()
+	defer c.Close()
+	c.Set("key", "value")
+
+	if err := c.Migrate(gorsy_cache.ARC); err != nil {
+		t.Fatal(err)
+	}
+	if w, want := c.Stats().Weight, gorsy_cache.MemoryWeigher(gorsy_cache.ARC)("key", "value"); w != want {
+		t.Errorf("weight %d after the migration to arc, want %d", w, want)
+	}
+}
@@ -0,0 +1,185 @@
+package gorsy_cache
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The operations replayed against a cache and its model.
+const (
+	opSet = iota
+	opGet
+	opRemove
+	opKinds
+)
+
+type op struct {
+	kind, key int
+}
+
+func (o op) String() string {
+	return fmt.Sprintf("%s(%d)", [...]string{"Set", "Get", "Remove"}[o.kind], o.key)
+}
+
+// models are the reference models of the built-in policies.
+var models = map[string]func(size int) model{
+	LRU: newLRUModel,
+	LFU: newLFUModel,
+	ARC: newARCModel,
+}
+
+// replay runs ops against a cache of the policy and its model, and reports the first disagreement of them.
+// A panic of the cache is reported as well, so that the sequence causing it is shrunk.
+func replay(policy string, size int, ops []op) (err error) {
+	m := models[policy](size)
+	step := 0
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("step %d %v: panic: %v", step, ops[step], r)
+		}
+	}()
+
+	var evicted []int
+	b, err := NewBuilder(policy, size)
+	if err != nil {
+		return err
+	}
+	c := b.
+		SetDefaultExpiration(NoExpiration).
+		SetPurgeInterval(NoPurge).
+		SetEvictedFunc(func(key, _ interface{}, reason EvictionReason) {
+			if reason == EvictionCapacity {
+				evicted = append(evicted, key.(int))
+			}
+		}).
+		Build()
+	defer c.Close()
+
+	for i, o := range ops {
+		step = i
+		evicted = evicted[:0]
+		switch o.kind {
+		case opSet:
+			c.Set(o.key, o.key)
+			if err := m.set(o.key, evicted); err != nil {
+				return fmt.Errorf("step %d %v: %v", i, o, err)
+			}
+		case opGet:
+			_, got := c.GetOnlyPresent(o.key)
+			if want := m.get(o.key); got != want {
+				return fmt.Errorf("step %d %v: hit %v, want %v", i, o, got, want)
+			}
+		case opRemove:
+			if got, want := c.Remove(o.key), m.remove(o.key); got != want {
+				return fmt.Errorf("step %d %v: removed %v, want %v", i, o, got, want)
+			}
+		}
+
+		keys := make([]int, 0)
+		for _, k := range c.Keys() {
+			keys = append(keys, k.(int))
+		}
+		if got, want := sortedKeys(keys), m.keys(); fmt.Sprint(got) != fmt.Sprint(want) {
+			return fmt.Errorf("step %d %v: keys %v, want %v", i, o, got, want)
+		}
+	}
+	return nil
+}
+
+// shrink returns a shortest sequence found failing like ops, by dropping chunks of the operations, halving the
+// chunks down to single operations, and then lowering the keys.
+func shrink(ops []op, fails func([]op) bool) []op {
+	for n := len(ops) / 2; n >= 1; n /= 2 {
+		for i := 0; i+n <= len(ops); {
+			candidate := append(append([]op{}, ops[:i]...), ops[i+n:]...)
+			if fails(candidate) {
+				ops = candidate
+			} else {
+				i += n
+			}
+		}
+	}
+
+	for i := range ops {
+		for k := 0; k < ops[i].key; k++ {
+			candidate := append([]op{}, ops...)
+			candidate[i].key = k
+			if fails(candidate) {
+				ops = candidate
+				break
+			}
+		}
+	}
+	return ops
+}
+
+// checkModel replays ops, and fails the test with the shrunk sequence if the cache disagrees with its model.
+func checkModel(t *testing.T, policy string, size int, ops []op) {
+	t.Helper()
+
+	err := replay(policy, size, ops)
+	if err == nil {
+		return
+	}
+	minimal := shrink(ops, func(ops []op) bool {
+		return replay(policy, size, ops) != nil
+	})
+	names := make([]string, len(minimal))
+	for i, o := range minimal {
+		names[i] = o.String()
+	}
+	t.Fatalf("%s of size %d disagrees with the model: %v\nshrunk from %d to %d operations: %s\n%v",
+		policy, size, err, len(ops), len(minimal), strings.Join(names, ", "), replay(policy, size, minimal))
+}
+
+// randomOps returns n random operations on a few more keys than size, so the keys are evicted and come back.
+func randomOps(r *rand.Rand, size, n int) []op {
+	ops := make([]op, n)
+	for i := range ops {
+		ops[i] = op{kind: r.Intn(opKinds), key: r.Intn(3 * size)}
+	}
+	return ops
+}
+
+func testModel(t *testing.T, policy string) {
+	runs := 500
+	if testing.Short() {
+		runs = 50
+	}
+	seed := time.Now().UnixNano()
+	t.Logf("seed %d", seed)
+	r := rand.New(rand.NewSource(seed))
+	for i := 0; i < runs; i++ {
+		size := 1 + r.Intn(8)
+		checkModel(t, policy, size, randomOps(r, size, 200))
+	}
+}
+
+func TestLRUModel(t *testing.T) { testModel(t, LRU) }
+func TestLFUModel(t *testing.T) { testModel(t, LFU) }
+func TestARCModel(t *testing.T) { testModel(t, ARC) }
+
+// decodeOps turns the fuzzed bytes into operations, a byte for each.
+func decodeOps(size int, data []byte) []op {
+	ops := make([]op, len(data))
+	for i, b := range data {
+		ops[i] = op{kind: int(b) % opKinds, key: int(b) / opKinds % (3 * size)}
+	}
+	return ops
+}
+
+func fuzzModel(f *testing.F, policy string) {
+	f.Add(uint8(2), []byte{0, 3, 6, 9, 3, 12, 15, 0, 6})
+	f.Add(uint8(4), []byte{0, 3, 6, 9, 12, 1, 4, 15, 18, 0, 21, 3, 24, 2, 27, 30})
+	f.Fuzz(func(t *testing.T, size uint8, data []byte) {
+		n := 1 + int(size)%8
+		checkModel(t, policy, n, decodeOps(n, data))
+	})
+}
+
+func FuzzLRU(f *testing.F) { fuzzModel(f, LRU) }
+func FuzzLFU(f *testing.F) { fuzzModel(f, LFU) }
+func FuzzARC(f *testing.F) { fuzzModel(f, ARC) }
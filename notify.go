@@ -0,0 +1,190 @@
+package gorsy_cache
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+)
+
+// EvictionDelivery picks when the eviction callbacks are called, see SetEvictionDelivery.
+type EvictionDelivery int
+
+const (
+	// DeliverLocked calls the callbacks as soon as a item leaves the store, with the cache lock held.
+	// A callback using the same cache deadlocks, and a slow one stalls all of the other operations.
+	DeliverLocked EvictionDelivery = iota
+	// DeliverAfterUnlock queues the notifications under the lock, and calls the callbacks in the goroutine
+	// of the operation right after the lock is released.
+	DeliverAfterUnlock
+	// DeliverAsync hands the notifications to a bounded pool of workers after the lock is released,
+	// so the operations never wait for the callbacks unless the queue overflows.
+	DeliverAsync
+)
+
+// OverflowPolicy decides what happens to a notification when the queue of the workers is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock waits until the workers make room in the queue. While all of the workers are in the
+	// callbacks, the caller delivers the notification instead, as it may be a callback writing to the cache.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDrop discards the notification, the callbacks are never called for it.
+	OverflowDrop
+	// OverflowCallerRuns calls the callbacks in the goroutine of the operation, like DeliverAfterUnlock.
+	OverflowCallerRuns
+)
+
+const (
+	defaultEvictionWorkers = 1
+	defaultEvictionQueue   = 1024
+)
+
+// EvictionPanicFunc reports a panic recovered from the eviction callbacks of a item.
+type EvictionPanicFunc func(key, value interface{}, reason EvictionReason, recovered interface{})
+
+func defaultEvictionPanic(key, _ interface{}, reason EvictionReason, recovered interface{}) {
+	log.Printf("gorsy_cache: eviction callback of %v (%s) panicked: %v", key, reason, recovered)
+}
+
+// eviction is a item left the store, queued to notify the callbacks.
+type eviction struct {
+	key, value interface{}
+	reason     EvictionReason
+}
+
+// notify calls the callbacks for the evicted item, recovering and reporting a panic of them.
+func (c *baseCache) notify(e eviction) {
+	defer func() {
+		if r := recover(); r != nil {
+			report := c.EvictionPanicFunc
+			if report == nil {
+				report = defaultEvictionPanic
+			}
+			report(e.key, e.value, e.reason, r)
+		}
+	}()
+
+	if c.BeforeEvictedFunc != nil && e.reason != EvictionReplaced {
+		c.BeforeEvictedFunc(e.key, e.value)
+	}
+	if c.EvictedFunc != nil {
+		c.EvictedFunc(e.key, e.value, e.reason)
+	}
+}
+
+// unlockAndNotify releases the write lock, and then delivers the notifications queued while it was held.
+func (c *baseCache) unlockAndNotify() {
+	pending := c.pending
+	c.pending = nil
+	c.Unlock()
+
+	for _, e := range pending {
+		if c.delivery != DeliverAsync || !c.workers.submit(c, e) {
+			c.notify(e)
+		}
+	}
+}
+
+// evictionWorkers is a bounded pool of goroutines delivering the notifications of DeliverAsync.
+// It is shared by the shards of a sharded cache.
+type evictionWorkers struct {
+	mu       sync.RWMutex
+	closed   bool
+	queue    chan asyncEviction
+	overflow OverflowPolicy
+	wg       sync.WaitGroup
+	// stopping is closed by close to wake the senders blocked on the queue, which is only closed once
+	// all of the senders counted by senders have left.
+	stopping chan struct{}
+	senders  sync.WaitGroup
+	// n is the number of the workers, and busy the number of them in a callback, accessed atomically.
+	n, busy int32
+}
+
+type asyncEviction struct {
+	c *baseCache
+	e eviction
+}
+
+func newEvictionWorkers(n, size int, overflow OverflowPolicy) *evictionWorkers {
+	w := &evictionWorkers{
+		queue:    make(chan asyncEviction, size),
+		overflow: overflow,
+		stopping: make(chan struct{}),
+		n:        int32(n),
+	}
+	w.wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer w.wg.Done()
+			for a := range w.queue {
+				atomic.AddInt32(&w.busy, 1)
+				a.c.notify(a.e)
+				atomic.AddInt32(&w.busy, -1)
+			}
+		}()
+	}
+	return w
+}
+
+// submit queues the notification by the overflow policy. It returns false if the caller has to deliver it,
+// which happens after the workers were closed or when the queue is full under OverflowCallerRuns.
+//
+// Under OverflowBlock, a full queue is waited for only while a worker is out of the callbacks. Once all of
+// them are in the callbacks, the submit may come from one of them writing to the same cache, which would
+// wait for a queue only it drains, so the caller delivers the notification instead.
+func (w *evictionWorkers) submit(c *baseCache, e eviction) bool {
+	a := asyncEviction{c, e}
+	w.mu.RLock()
+	if w.closed {
+		w.mu.RUnlock()
+		return false
+	}
+	select {
+	case w.queue <- a:
+		w.mu.RUnlock()
+		return true
+	default:
+	}
+
+	switch w.overflow {
+	case OverflowDrop:
+		w.mu.RUnlock()
+		return true
+	case OverflowCallerRuns:
+		w.mu.RUnlock()
+		return false
+	}
+	if atomic.LoadInt32(&w.busy) >= w.n {
+		w.mu.RUnlock()
+		return false
+	}
+	// the lock is not held while waiting, so that close is never blocked by a full queue
+	w.senders.Add(1)
+	w.mu.RUnlock()
+	defer w.senders.Done()
+
+	select {
+	case w.queue <- a:
+		return true
+	case <-w.stopping:
+		return false
+	}
+}
+
+// close stops the workers after the queued notifications are delivered.
+// The senders waiting for the queue deliver their notifications themselves.
+func (w *evictionWorkers) close() {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return
+	}
+	w.closed = true
+	close(w.stopping)
+	w.mu.Unlock()
+
+	w.senders.Wait()
+	close(w.queue)
+	w.wg.Wait()
+}
@@ -0,0 +1,52 @@
+package gorsy_cache_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	gorsy_cache "github.com/arianxx/gorsy-cache"
+)
+
+// TestAsyncCallbackWritesCache fills a small queue by the callbacks writing to the same cache,
+// which must not stall the only worker draining it.
+func TestAsyncCallbackWritesCache(t *testing.T) {
+	var c gorsy_cache.Cache
+	b, err := gorsy_cache.NewBuilder(gorsy_cache.LRU, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c = b.
+		SetPurgeInterval(gorsy_cache.NoPurge).
+		SetEvictionDelivery(gorsy_cache.DeliverAsync).
+		SetEvictionWorkers(1, 2, gorsy_cache.OverflowBlock).
+		SetEvictedFunc(func(key, value interface{}, reason gorsy_cache.EvictionReason) {
+			if k, ok := key.(int); ok && reason == gorsy_cache.EvictionCapacity {
+				c.Set(string(rune('a'+k%26)), value)
+			}
+		}).
+		Build()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for n := i; n < 4000; n += 4 {
+					c.Set(n, n)
+				}
+			}(i)
+		}
+		wg.Wait()
+		c.Close()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the writers are stuck on the queue of the eviction workers")
+	}
+}
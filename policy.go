@@ -0,0 +1,386 @@
+package gorsy_cache
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// Policy decides the eviction order of a cache store registered by RegisterPolicy.
+// The cache store keeps the items, the expiration and the loader, the policy only tracks the keys.
+// All of the methods are called with the cache lock held, so a policy needn't be concurrency-safe.
+type Policy interface {
+	// Init is called when the cache store is allocated or flushed, with the capacity of the store.
+	Init(size int)
+	// OnAccess is called when a present key is read or updated.
+	OnAccess(key interface{})
+	// OnInsert is called after a new key is stored.
+	OnInsert(key interface{})
+	// Victim picks the key to be evicted when the store is over its capacity.
+	// The key just inserted may be picked to reject it.
+	Victim() (key interface{}, ok bool)
+	// OnRemove is called after a key is removed from the store, whatever the reason is.
+	OnRemove(key interface{})
+}
+
+// ResizablePolicy is implemented by a policy depending on the capacity given to Init, so that it is told of
+// the new capacity when the cache store is resized. Resize is called before the items over the new capacity
+// are evicted.
+type ResizablePolicy interface {
+	Policy
+	Resize(size int)
+}
+
+// OrderedPolicy is implemented by a policy able to export its eviction order,
+// so that the order is kept by the snapshots of the cache store.
+type OrderedPolicy interface {
+	Policy
+	// Order returns the tracked keys, from the first to be evicted to the last.
+	Order() []interface{}
+}
+
+// FrequencyPolicy is implemented by a policy counting the accesses of the keys,
+// so that the counts are kept by the snapshots of the cache store.
+type FrequencyPolicy interface {
+	Policy
+	// Frequency returns the number of the accesses of a tracked key after inserting, it may be estimated.
+	Frequency(key interface{}) int
+}
+
+// RegisterPolicy registers a eviction policy, so that a cache using it can be built by NewBuilder(name, size).
+// The constructor is called once for every cache store built.
+func RegisterPolicy(name string, constructor func() Policy) error {
+	if name == "" || constructor == nil {
+		return fmt.Errorf("policy name and constructor are required")
+	}
+
+	cacheCollectedMu.Lock()
+	defer cacheCollectedMu.Unlock()
+
+	if _, ok := cacheCollected[name]; ok {
+		return fmt.Errorf("cache `%s` has been registered", name)
+	}
+	cacheCollected[name] = func() interface{} {
+		return &policyCache{evictor: constructor()}
+	}
+	return nil
+}
+
+// Policies returns the names of the built-in and the registered policies, sorted.
+func Policies() []string {
+	cacheCollectedMu.RLock()
+	defer cacheCollectedMu.RUnlock()
+
+	names := make([]string, 0, len(cacheCollected))
+	for name := range cacheCollected {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// policyCache is the cache store of the policies registered by RegisterPolicy.
+type policyCache struct {
+	baseCache
+	evictor Policy
+	items   map[interface{}]*policyItem
+}
+
+func (c *policyCache) getBaseCache() *baseCache {
+	return &c.baseCache
+}
+
+func (c *policyCache) Init() {
+	c.resetItems()
+	c.items = make(map[interface{}]*policyItem, c.size)
+	c.evictor.Init(c.size)
+}
+
+func (c *policyCache) Get(key interface{}) (interface{}, error) {
+	c.Lock()
+	v, err := c.get(key)
+	c.Unlock()
+	if err == nil {
+		c.stats.hit()
+		return v, nil
+	}
+
+	c.stats.miss()
+	return c.load(key, c.get, c.Set)
+}
+
+func (c *policyCache) GetOnlyPresent(key interface{}) (interface{}, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	v, err := c.get(key)
+	if err != nil {
+		c.stats.miss()
+		return nil, false
+	} else {
+		c.stats.hit()
+		return v, true
+	}
+}
+
+func (c *policyCache) get(key interface{}) (interface{}, error) {
+	item, ok := c.items[key]
+	if ok && !item.isExpired(c.now()) {
+		c.evictor.OnAccess(key)
+		return item.value, nil
+	}
+
+	return nil, &KeyNotFoundError{c.Name, key, nil}
+}
+
+func (c *policyCache) Set(key, value interface{}) {
+	c.Lock()
+	defer c.unlockAndNotify()
+
+	c.set(key, value, DefaultExpiration)
+}
+
+func (c *policyCache) set(k, v interface{}, e time.Duration) {
+	w := c.weigh(k, v)
+	if c.tooHeavy(w) {
+		c.remove(k, EvictionCapacity)
+		c.reject(k, v)
+		return
+	}
+
+	item, ok := c.items[k]
+	if ok {
+		old := item.value
+		item.value = v
+		item.setExpiration(e, &c.baseCache)
+		c.setWeight(&item.baseItem, w)
+		c.evictor.OnAccess(k)
+		c.evicted(k, old, EvictionReplaced)
+	} else {
+		item = &policyItem{newBaseItem(k, v)}
+		item.setExpiration(e, &c.baseCache)
+		c.setWeight(&item.baseItem, w)
+		c.items[k] = item
+		c.evictor.OnInsert(k)
+	}
+
+	c.evictOver()
+}
+
+// evictOver evicts the items over the capacity or the max weight.
+func (c *policyCache) evictOver() {
+	if c.capacity() > 0 && len(c.items) > c.capacity() {
+		c.evict(len(c.items) - c.capacity())
+	}
+	for c.overWeight(0) {
+		if !c.evict(1) {
+			return
+		}
+	}
+}
+
+func (c *policyCache) SetWithExpire(k, v interface{}, e time.Duration) {
+	c.Lock()
+	defer c.unlockAndNotify()
+
+	c.set(k, v, e)
+}
+
+// evict evicts size items picked by the policy, it returns false if the policy picked none.
+func (c *policyCache) evict(size int) bool {
+	for i := 0; i < size; i++ {
+		k, ok := c.evictor.Victim()
+		if !ok {
+			return false
+		}
+		item, ok := c.items[k]
+		if !ok {
+			// a broken policy picking a missing key would never release any space.
+			return false
+		}
+		delete(c.items, k)
+		c.forget(&item.baseItem)
+		c.evictor.OnRemove(k)
+		c.evicted(k, item.value, EvictionCapacity)
+	}
+	return true
+}
+
+func (c *policyCache) Has(key interface{}) bool {
+	c.RLock()
+	defer c.RUnlock()
+
+	item, ok := c.items[key]
+	return ok && !item.isExpired(c.now())
+}
+
+func (c *policyCache) TTL(key interface{}) (time.Duration, bool) {
+	c.RLock()
+	defer c.RUnlock()
+
+	now := c.now()
+	item, ok := c.items[key]
+	if !ok || item.isExpired(now) {
+		return 0, false
+	}
+	return item.ttl(now), true
+}
+
+func (c *policyCache) Remove(key interface{}) bool {
+	c.Lock()
+	defer c.unlockAndNotify()
+
+	return c.remove(key, EvictionExplicit)
+}
+
+func (c *policyCache) remove(key interface{}, reason EvictionReason) bool {
+	item, ok := c.items[key]
+	if !ok {
+		return false
+	}
+	delete(c.items, key)
+	c.forget(&item.baseItem)
+	c.evictor.OnRemove(key)
+	c.evicted(key, item.value, reason)
+
+	return !item.isExpired(c.now())
+}
+
+func (c *policyCache) Keys() []interface{} {
+	c.RLock()
+	defer c.RUnlock()
+
+	keys := make([]interface{}, 0)
+	now := c.now()
+	for k, v := range c.items {
+		if !v.isExpired(now) {
+			keys = append(keys, k)
+		}
+	}
+
+	return keys
+}
+
+func (c *policyCache) CleanExpired() int {
+	return c.cleanExpired(func(key interface{}) {
+		c.remove(key, EvictionExpired)
+	})
+}
+
+func (c *policyCache) Flush() {
+	c.Lock()
+	defer c.unlockAndNotify()
+
+	for k, item := range c.items {
+		c.evicted(k, item.value, EvictionFlushed)
+	}
+	c.Init()
+}
+
+func (c *policyCache) drop() {
+	c.Lock()
+	defer c.Unlock()
+
+	c.stats.evicted(EvictionFlushed, len(c.items))
+	c.Init()
+}
+
+func (c *policyCache) Resize(n int) {
+	if n < 1 {
+		return
+	}
+	c.Lock()
+	defer c.unlockAndNotify()
+
+	c.size = n
+	if p, ok := c.evictor.(ResizablePolicy); ok {
+		p.Resize(n)
+	}
+	c.evictOver()
+}
+
+func (c *policyCache) setLimit(n int) {
+	c.Lock()
+	defer c.unlockAndNotify()
+
+	c.limit = n
+	c.evictOver()
+}
+
+func (c *policyCache) Len() int {
+	c.RLock()
+	defer c.RUnlock()
+
+	return len(c.items)
+}
+
+func (c *policyCache) Stats() Stats {
+	c.RLock()
+	defer c.RUnlock()
+
+	return c.snapshotStats(len(c.items))
+}
+
+func (c *policyCache) SaveTo(w io.Writer) error {
+	return saveTo(c, w)
+}
+
+func (c *policyCache) LoadFrom(r io.Reader) error {
+	return loadFrom(c, r)
+}
+
+func (c *policyCache) snapshot() *snapshot {
+	c.RLock()
+	defer c.RUnlock()
+
+	var keys []interface{}
+	if p, ok := c.evictor.(OrderedPolicy); ok {
+		keys = p.Order()
+	} else {
+		keys = make([]interface{}, 0, len(c.items))
+		for k := range c.items {
+			keys = append(keys, k)
+		}
+	}
+
+	freqs, _ := c.evictor.(FrequencyPolicy)
+	s := &snapshot{Policy: c.policy, Entries: make([]snapshotEntry, 0, len(keys))}
+	now := c.now()
+	for _, k := range keys {
+		if item, ok := c.items[k]; ok && !item.isExpired(now) {
+			e := newSnapshotEntry(&item.baseItem)
+			if freqs != nil {
+				e.Freq = freqs.Frequency(k)
+			}
+			s.Entries = append(s.Entries, e)
+		}
+	}
+	return s
+}
+
+func (c *policyCache) restore(s *snapshot) {
+	c.Lock()
+	defer c.unlockAndNotify()
+
+	for _, e := range s.Entries {
+		c.set(e.Key, e.Value, NoExpiration)
+		item, ok := c.items[e.Key]
+		if !ok {
+			continue
+		}
+		item.expireAt(e.Expiration, &c.baseCache)
+		// replay the accesses known by the policy the entry comes from, so that a frequency based
+		// policy learns the hot keys. The count is bounded, as the sketches saturate early.
+		for i := min(e.accesses(), maxReplayedAccesses); i > 0; i-- {
+			c.evictor.OnAccess(e.Key)
+		}
+	}
+}
+
+// maxReplayedAccesses bounds the accesses replayed to the policy for a restored entry.
+const maxReplayedAccesses = cmMaxCounter
+
+type policyItem struct {
+	baseItem
+}
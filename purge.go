@@ -1,37 +1,199 @@
 package gorsy_cache
 
 import (
+	"container/heap"
 	"fmt"
+	"sync"
 	"time"
 )
 
-var purging = make(map[*Cache]chan struct{})
+// schedulers schedule the collection of the expired items of all of the caches, one for every clock.
+var (
+	schedulersMu sync.Mutex
+	schedulers   = make(map[Clock]*purgeScheduler)
+)
+
+// minDuration is the shortest purge interval and default expiration. The shorter ones are mostly bare numbers
+// of seconds of the earlier versions, such as 60, which would purge or expire all the time.
+const minDuration = time.Millisecond
 
+// StartPurge starts to collect the expired items of the cache every d.
+// The cache built by Builder is purged by its PurgeInterval, until it is closed.
+// Like its other durations, d is counted in seconds for a cache built with LegacySeconds. An interval under
+// a millisecond is rejected, as it is mostly a bare number of seconds, such as 60, that would purge all the time.
 func StartPurge(c *Cache, d time.Duration) error {
-	if _, ok := purging[c]; ok {
-		return fmt.Errorf("%v has been started to purge", c)
+	bc := (*c).getBaseCache()
+	if bc.legacySeconds {
+		d = bc.legacyDuration(d)
+	}
+	if d > 0 && d < minDuration {
+		return fmt.Errorf("purge interval %s is under %s, pass a duration such as %d*time.Second", d, minDuration, d)
 	}
+	return startPurge(*c, d)
+}
 
-	purging[c] = make(chan struct{})
-	go func() {
-		t := time.NewTicker(d * time.Second)
-		for {
-			select {
-			case <-purging[c]:
-				t.Stop()
-				delete(purging, c)
-				return
-			case <-t.C:
-				(*c).CleanExpired()
-			}
-		}
-	}()
+// StopPurge stops the purging started by StartPurge.
+func StopPurge(c *Cache) {
+	stopPurge(*c)
+}
+
+func startPurge(c Cache, d time.Duration) error {
+	clock := c.getBaseCache().clockOrDefault()
+
+	schedulersMu.Lock()
+	defer schedulersMu.Unlock()
+
+	s, ok := schedulers[clock]
+	if !ok {
+		s = newPurgeScheduler(clock)
+		schedulers[clock] = s
+	}
+	return s.add(c, d)
+}
+
+// stopPurge stops purging c, the scheduler of a clock is dropped once none of its caches is left.
+func stopPurge(c Cache) {
+	clock := c.getBaseCache().clockOrDefault()
+
+	schedulersMu.Lock()
+	defer schedulersMu.Unlock()
 
+	s, ok := schedulers[clock]
+	if !ok {
+		return
+	}
+	if s.remove(c) == 0 {
+		s.stop()
+		delete(schedulers, clock)
+	}
+}
+
+// purgeScheduler serves all of the caches of a clock by a single timer, which fires at the earliest purge due.
+// The due caches are purged one by one in the goroutine of the timer, and the next timer is only set after
+// the purging has been done, so at most one goroutine is purging at any time.
+type purgeScheduler struct {
+	mu      sync.Mutex
+	clock   Clock
+	queue   purgeQueue
+	entries map[Cache]*purgeEntry
+	timer   Timer
+	// running is set while the due caches are being purged.
+	running bool
+}
+
+type purgeEntry struct {
+	c        Cache
+	interval time.Duration
+	next     time.Time
+	index    int
+}
+
+func newPurgeScheduler(clock Clock) *purgeScheduler {
+	return &purgeScheduler{clock: clock, entries: make(map[Cache]*purgeEntry)}
+}
+
+func (s *purgeScheduler) add(c Cache, d time.Duration) error {
+	if d <= 0 {
+		return fmt.Errorf("purge interval must be positive")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.entries[c]; ok {
+		return fmt.Errorf("%v has been started to purge", c)
+	}
+	e := &purgeEntry{c: c, interval: d, next: s.clock.Now().Add(d)}
+	heap.Push(&s.queue, e)
+	s.entries[c] = e
+	s.arm()
 	return nil
 }
 
-func StopPurge(c *Cache) {
-	if _, ok := purging[c]; ok {
-		purging[c] <- struct{}{}
+// remove stops purging c, and returns the number of the caches left.
+func (s *purgeScheduler) remove(c Cache) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[c]; ok {
+		heap.Remove(&s.queue, e.index)
+		delete(s.entries, c)
+	}
+	return len(s.entries)
+}
+
+// stop stops the timer, a purging running at the time goes on.
+func (s *purgeScheduler) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+}
+
+// arm sets the timer to the earliest purge due, it must be called with s.mu held.
+func (s *purgeScheduler) arm() {
+	if s.running || len(s.queue) == 0 {
+		return
+	}
+
+	d := s.queue[0].next.Sub(s.clock.Now())
+	if s.timer == nil {
+		s.timer = s.clock.AfterFunc(d, s.run)
+	} else {
+		s.timer.Reset(d)
+	}
+}
+
+func (s *purgeScheduler) run() {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = true
+
+	now := s.clock.Now()
+	due := make([]Cache, 0)
+	for len(s.queue) > 0 && !s.queue[0].next.After(now) {
+		e := s.queue[0]
+		due = append(due, e.c)
+		e.next = now.Add(e.interval)
+		heap.Fix(&s.queue, 0)
+	}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		s.running = false
+		s.arm()
+		s.mu.Unlock()
+	}()
+
+	for _, c := range due {
+		c.CleanExpired()
 	}
 }
+
+// purgeQueue is a min-heap of the purge entries ordered by the next purge time.
+type purgeQueue []*purgeEntry
+
+func (q purgeQueue) Len() int           { return len(q) }
+func (q purgeQueue) Less(i, j int) bool { return q[i].next.Before(q[j].next) }
+func (q purgeQueue) Swap(i, j int) {
+	q[i].index, q[j].index = j, i
+	q[i], q[j] = q[j], q[i]
+}
+
+func (q *purgeQueue) Push(x interface{}) {
+	x.(*purgeEntry).index = len(*q)
+	*q = append(*q, x.(*purgeEntry))
+}
+
+func (q *purgeQueue) Pop() interface{} {
+	x, n := (*q)[len(*q)-1], (*q)[:len(*q)-1]
+	(*q)[len(*q)-1] = nil
+	*q = n
+	return x
+}
@@ -0,0 +1,232 @@
+package gorsy_cache_test
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	gorsy_cache "github.com/arianxx/gorsy-cache"
+	"github.com/arianxx/gorsy-cache/cachetest"
+)
+
+func TestStartPurgeRejectsBareNumbers(t *testing.T) {
+	b, err := gorsy_cache.NewBuilder(gorsy_cache.LRU, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := b.SetPurgeInterval(gorsy_cache.NoPurge).Build()
+	defer c.Close()
+
+	if err := gorsy_cache.StartPurge(&c, 60); err == nil {
+		t.Error("StartPurge accepted a interval of 60ns")
+	}
+	if err := gorsy_cache.StartPurge(&c, time.Minute); err != nil {
+		t.Errorf("StartPurge(time.Minute) = %v", err)
+	}
+	gorsy_cache.StopPurge(&c)
+}
+
+// TestStartPurgeLegacySeconds counts the interval in seconds for a LegacySeconds cache.
+func TestStartPurgeLegacySeconds(t *testing.T) {
+	clock := cachetest.NewFakeClock(time.Unix(0, 0))
+	b, err := gorsy_cache.NewBuilder(gorsy_cache.LRU, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := b.LegacySeconds().SetClock(clock).SetPurgeInterval(gorsy_cache.NoPurge).Build()
+	defer c.Close()
+
+	if err := gorsy_cache.StartPurge(&c, 60); err != nil {
+		t.Fatal(err)
+	}
+	defer gorsy_cache.StopPurge(&c)
+
+	c.SetWithExpire("key", "value", 1)
+	clock.Advance(59 * time.Second)
+	if n := c.Stats().ExpiredEvictions; n != 0 {
+		t.Errorf("%d items purged before 60s", n)
+	}
+	clock.Advance(time.Second)
+	if n := c.Stats().ExpiredEvictions; n != 1 {
+		t.Errorf("%d items purged after 60s, want 1", n)
+	}
+}
+
+// TestBuildBareSeconds takes the bare numbers given to the builder as seconds, as the earlier versions did.
+func TestBuildBareSeconds(t *testing.T) {
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+
+	clock := cachetest.NewFakeClock(time.Unix(0, 0))
+	b, err := gorsy_cache.NewBuilder(gorsy_cache.LRU, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := b.SetClock(clock).SetDefaultExpiration(60).SetPurgeInterval(30).Build()
+	defer c.Close()
+
+	c.Set("key", "value")
+	if _, err := c.Get("key"); err != nil {
+		t.Errorf("the item set is missing right away: %v", err)
+	}
+	if ttl, _ := c.TTL("key"); ttl != 60*time.Second {
+		t.Errorf("TTL = %s, want 60s", ttl)
+	}
+	if !strings.Contains(logged.String(), "default expiration 60ns") || !strings.Contains(logged.String(), "purge interval 30ns") {
+		t.Errorf("the conversions are not logged: %q", logged.String())
+	}
+
+	// the purge runs every 30s, not in a loop
+	c.SetWithExpire("short", "value", 10*time.Second)
+	clock.Advance(29 * time.Second)
+	if n := c.Stats().ExpiredEvictions; n != 0 {
+		t.Errorf("%d items purged before 30s", n)
+	}
+	clock.Advance(time.Second)
+	if n := c.Stats().ExpiredEvictions; n != 1 {
+		t.Errorf("%d items purged after 30s, want 1", n)
+	}
+	// the key set first expires after 60s, and is purged by the round at 90s
+	clock.Advance(60 * time.Second)
+	if n := c.Stats().ExpiredEvictions; n != 2 {
+		t.Errorf("%d items purged after 90s, want 2", n)
+	}
+}
+
+// funcClock is a Clock that can't be compared by ==.
+type funcClock struct {
+	now   func() time.Time
+	after func(d time.Duration, f func()) gorsy_cache.Timer
+}
+
+func (c funcClock) Now() time.Time { return c.now() }
+
+func (c funcClock) AfterFunc(d time.Duration, f func()) gorsy_cache.Timer { return c.after(d, f) }
+
+// boxedClock is comparable by its type, but == panics on the func in its field.
+type boxedClock struct {
+	gorsy_cache.Clock
+}
+
+func TestUncomparableClock(t *testing.T) {
+	fake := cachetest.NewFakeClock(time.Unix(0, 0))
+	for _, clock := range []gorsy_cache.Clock{
+		funcClock{fake.Now, fake.AfterFunc},
+		boxedClock{funcClock{fake.Now, fake.AfterFunc}},
+	} {
+		b, err := gorsy_cache.NewBuilder(gorsy_cache.LRU, 8)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.SetClock(clock).SetPurgeInterval(time.Second)
+		c1, c2 := b.Build(), b.Build()
+
+		c1.SetWithExpire("key", "value", time.Second)
+		c2.SetWithExpire("key", "value", time.Second)
+		fake.Advance(2 * time.Second)
+		if n := c1.Stats().ExpiredEvictions + c2.Stats().ExpiredEvictions; n != 2 {
+			t.Errorf("%T: %d items purged, want 2", clock, n)
+		}
+		c1.Close()
+		c2.Close()
+	}
+}
+
+// TestDroppedCacheCollected drops a cache built with NoPurge without closing it, nothing holds it.
+func TestDroppedCacheCollected(t *testing.T) {
+	collected := make(chan struct{})
+	func() {
+		b, err := gorsy_cache.NewBuilder(gorsy_cache.LRU, 8)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := b.SetPurgeInterval(gorsy_cache.NoPurge).Build()
+		// the value is collected with the cache, the cache itself is a cycle its finalizer would keep alive
+		v := new([16]int64)
+		runtime.SetFinalizer(v, func(*[16]int64) { close(collected) })
+		c.Set("key", v)
+	}()
+
+	for i := 0; i < 10; i++ {
+		runtime.GC()
+		select {
+		case <-collected:
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	t.Error("the cache dropped is never collected")
+}
+
+func sampledCache(t *testing.T, clock gorsy_cache.Clock) gorsy_cache.Cache {
+	t.Helper()
+	b, err := gorsy_cache.NewBuilder(gorsy_cache.LRU, 2000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := b.
+		SetClock(clock).
+		SetPurgeInterval(time.Minute).
+		SetPurgeMode(gorsy_cache.PurgeSampled).
+		SetPurgeSampling(20, 0.25).
+		Build()
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// TestPurgeSampledDense goes on with the rounds while most of the samples are expired, until all are removed.
+func TestPurgeSampledDense(t *testing.T) {
+	clock := cachetest.NewFakeClock(time.Unix(0, 0))
+	c := sampledCache(t, clock)
+	for k := 0; k < 200; k++ {
+		c.SetWithExpire(k, k, time.Second)
+	}
+	for k := 200; k < 300; k++ {
+		c.SetWithExpire(k, k, gorsy_cache.NoExpiration)
+	}
+
+	clock.Advance(time.Minute)
+	if n := c.Stats().ExpiredEvictions; n != 200 {
+		t.Errorf("%d items purged, want the 200 expired", n)
+	}
+	if n := c.Len(); n != 100 {
+		t.Errorf("Len() = %d, want the 100 permanent items", n)
+	}
+}
+
+// TestPurgeSampledSparse stops after a round finding few of its samples expired, leaving the others to the reads
+// and the later rounds.
+func TestPurgeSampledSparse(t *testing.T) {
+	clock := cachetest.NewFakeClock(time.Unix(0, 0))
+	c := sampledCache(t, clock)
+	for k := 0; k < 5; k++ {
+		c.SetWithExpire(k, k, time.Second)
+	}
+	for k := 5; k < 1005; k++ {
+		c.SetWithExpire(k, k, time.Hour)
+	}
+
+	clock.Advance(2 * time.Second)
+	// a round of 20 samples finds at most the 5 expired items, which is not over 0.25 of them
+	n := c.CleanExpired()
+	if n > 5 {
+		t.Errorf("%d items purged, want at most 5", n)
+	}
+	// the odds of finding all of them in 20 samples of 1005 items are about 3e-9
+	if n == 5 {
+		t.Error("all of the sparse expired items were purged, the rounds went on")
+	}
+	if l := c.Len(); l != 1005-n {
+		t.Errorf("Len() = %d, want %d", l, 1005-n)
+	}
+	for k := 0; k < 5; k++ {
+		if c.Has(k) {
+			t.Errorf("%d is expired, but reported present", k)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package gorsy_cache
+
+import (
+	"fmt"
+	"sort"
+)
+
+// model is a slow and obviously correct eviction policy, the results of a cache are compared with.
+type model interface {
+	// set stores key, and checks the keys evicted by the cache to make room for it.
+	set(key int, evicted []int) error
+	get(key int) bool
+	remove(key int) bool
+	keys() []int
+}
+
+// lruModel keeps the keys from the least recently used to the most.
+type lruModel struct {
+	size  int
+	order []int
+}
+
+func newLRUModel(size int) model {
+	return &lruModel{size: size}
+}
+
+func (m *lruModel) set(key int, evicted []int) error {
+	var victims []int
+	if !m.get(key) {
+		if len(m.order) >= m.size {
+			victims = []int{m.order[0]}
+			m.order = m.order[1:]
+		}
+		m.order = append(m.order, key)
+	}
+	return sameEvictions(victims, evicted)
+}
+
+func (m *lruModel) get(key int) bool {
+	if !removeKey(&m.order, key) {
+		return false
+	}
+	m.order = append(m.order, key)
+	return true
+}
+
+func (m *lruModel) remove(key int) bool {
+	return removeKey(&m.order, key)
+}
+
+func (m *lruModel) keys() []int {
+	return sortedKeys(m.order)
+}
+
+// lfuModel counts the hits of every key. Any of the least frequent keys may be evicted, as the order of the ties
+// is left to the cache, so the model checks the victim picked by the cache is one of them and follows it.
+type lfuModel struct {
+	size  int
+	freqs map[int]int
+}
+
+func newLFUModel(size int) model {
+	return &lfuModel{size: size, freqs: make(map[int]int)}
+}
+
+func (m *lfuModel) set(key int, evicted []int) error {
+	if _, ok := m.freqs[key]; ok {
+		return sameEvictions(nil, evicted)
+	}
+
+	if len(m.freqs) >= m.size {
+		if len(evicted) != 1 {
+			return fmt.Errorf("evicted %v, want one of the least frequent keys", evicted)
+		}
+		least := -1
+		for _, f := range m.freqs {
+			if least < 0 || f < least {
+				least = f
+			}
+		}
+		f, ok := m.freqs[evicted[0]]
+		if !ok || f != least {
+			return fmt.Errorf("evicted %d of frequency %d, the least frequency is %d", evicted[0], f, least)
+		}
+		delete(m.freqs, evicted[0])
+	} else if err := sameEvictions(nil, evicted); err != nil {
+		return err
+	}
+	m.freqs[key] = 0
+	return nil
+}
+
+func (m *lfuModel) get(key int) bool {
+	if _, ok := m.freqs[key]; !ok {
+		return false
+	}
+	m.freqs[key]++
+	return true
+}
+
+func (m *lfuModel) remove(key int) bool {
+	if _, ok := m.freqs[key]; !ok {
+		return false
+	}
+	delete(m.freqs, key)
+	return true
+}
+
+func (m *lfuModel) keys() []int {
+	keys := make([]int, 0, len(m.freqs))
+	for k := range m.freqs {
+		keys = append(keys, k)
+	}
+	return sortedKeys(keys)
+}
+
+// arcModel follows the ARC of "ARC: A Self-Tuning, Low Overhead Replacement Cache" by Megiddo and Modha line by
+// line, every list is kept from the lru end. A removed key leaves t1 or t2 without a ghost, and REPLACE does
+// nothing while t1 and t2 are less than full after such removals.
+type arcModel struct {
+	c, p           int
+	t1, t2, b1, b2 []int
+}
+
+func newARCModel(size int) model {
+	return &arcModel{c: size, p: size / 2}
+}
+
+func (m *arcModel) set(x int, evicted []int) error {
+	var victims []int
+	var err error
+	switch {
+	case m.get(x):
+		// case I: a hit in t1 or t2.
+	case hasKey(m.b1, x):
+		// case II: a ghost hit in b1.
+		m.p = min(m.p+max(len(m.b2)/len(m.b1), 1), m.c)
+		victims, err = m.replace(x)
+		removeKey(&m.b1, x)
+		m.t2 = append(m.t2, x)
+	case hasKey(m.b2, x):
+		// case III: a ghost hit in b2.
+		m.p = max(m.p-max(len(m.b1)/len(m.b2), 1), 0)
+		victims, err = m.replace(x)
+		removeKey(&m.b2, x)
+		m.t2 = append(m.t2, x)
+	default:
+		// case IV: a miss.
+		if len(m.t1)+len(m.b1) == m.c {
+			if len(m.t1) < m.c {
+				m.b1 = m.b1[1:]
+				victims, err = m.replace(x)
+			} else {
+				victims = []int{m.t1[0]}
+				m.t1 = m.t1[1:]
+			}
+		} else if total := len(m.t1) + len(m.t2) + len(m.b1) + len(m.b2); total >= m.c {
+			if total == 2*m.c {
+				m.b2 = m.b2[1:]
+			}
+			victims, err = m.replace(x)
+		}
+		m.t1 = append(m.t1, x)
+	}
+	if err != nil {
+		return err
+	}
+	return sameEvictions(victims, evicted)
+}
+
+// replace is the subroutine REPLACE(x, p) of the paper.
+func (m *arcModel) replace(x int) ([]int, error) {
+	if len(m.t1)+len(m.t2) < m.c {
+		return nil, nil
+	}
+
+	if len(m.t1) > 0 && (len(m.t1) > m.p || (hasKey(m.b2, x) && len(m.t1) == m.p)) {
+		victim := m.t1[0]
+		m.t1 = m.t1[1:]
+		m.b1 = append(m.b1, victim)
+		return []int{victim}, nil
+	}
+	if len(m.t2) == 0 {
+		return nil, fmt.Errorf("REPLACE(%d) with t2 empty, t1 %v and p %d", x, m.t1, m.p)
+	}
+	victim := m.t2[0]
+	m.t2 = m.t2[1:]
+	m.b2 = append(m.b2, victim)
+	return []int{victim}, nil
+}
+
+func (m *arcModel) get(x int) bool {
+	if removeKey(&m.t1, x) || removeKey(&m.t2, x) {
+		m.t2 = append(m.t2, x)
+		return true
+	}
+	return false
+}
+
+func (m *arcModel) remove(x int) bool {
+	return removeKey(&m.t1, x) || removeKey(&m.t2, x)
+}
+
+func (m *arcModel) keys() []int {
+	return sortedKeys(append(append([]int{}, m.t1...), m.t2...))
+}
+
+func hasKey(keys []int, key int) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+func removeKey(keys *[]int, key int) bool {
+	for i, k := range *keys {
+		if k == key {
+			*keys = append((*keys)[:i], (*keys)[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(keys []int) []int {
+	sorted := append([]int{}, keys...)
+	sort.Ints(sorted)
+	return sorted
+}
+
+func sameEvictions(want, got []int) error {
+	if fmt.Sprint(want) != fmt.Sprint(got) && len(want)+len(got) > 0 {
+		return fmt.Errorf("evicted %v, want %v", got, want)
+	}
+	return nil
+}
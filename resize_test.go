@@ -0,0 +1,99 @@
+package gorsy_cache_test
+
+import (
+	"testing"
+
+	gorsy_cache "github.com/arianxx/gorsy-cache"
+)
+
+func TestResize(t *testing.T) {
+	for _, policy := range []string{
+		gorsy_cache.SIMPLE,
+		gorsy_cache.LRU,
+		gorsy_cache.LFU,
+		gorsy_cache.ARC,
+		gorsy_cache.TINYLFU,
+	} {
+		t.Run(policy, func(t *testing.T) {
+			b, err := gorsy_cache.NewBuilder(policy, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c := b.SetPurgeInterval(gorsy_cache.NoPurge).SetDefaultExpiration(gorsy_cache.NoExpiration).Build()
+			defer c.Close()
+			for k := 0; k < 10; k++ {
+				c.Set(k, k)
+			}
+
+			c.Resize(5)
+			if n, size := c.Len(), c.Stats().Capacity; n != 5 || size != 5 {
+				t.Errorf("Len() = %d and capacity %d after shrinking, want 5", n, size)
+			}
+			c.Resize(0)
+			if size := c.Stats().Capacity; size != 5 {
+				t.Errorf("capacity %d after Resize(0), want it ignored", size)
+			}
+
+			c.Resize(20)
+			for k := 100; k < 200; k++ {
+				c.Set(k, k)
+				c.Get(k)
+			}
+			if n, size := c.Len(), c.Stats().Capacity; n != 20 || size != 20 {
+				t.Errorf("Len() = %d and capacity %d after growing, want 20", n, size)
+			}
+		})
+	}
+}
+
+// TestResizeLRUOrder shrinks a lru cache, the most recently used items stay.
+func TestResizeLRUOrder(t *testing.T) {
+	b, err := gorsy_cache.NewBuilder(gorsy_cache.LRU, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := b.SetPurgeInterval(gorsy_cache.NoPurge).SetDefaultExpiration(gorsy_cache.NoExpiration).Build()
+	defer c.Close()
+	for k := 0; k < 10; k++ {
+		c.Set(k, k)
+	}
+	c.Get(0)
+
+	c.Resize(3)
+	for _, k := range []int{0, 8, 9} {
+		if !c.Has(k) {
+			t.Errorf("%d is evicted by the shrinking", k)
+		}
+	}
+}
+
+func TestResizeSharded(t *testing.T) {
+	b, err := gorsy_cache.NewShardedBuilder(gorsy_cache.LRU, 16, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := b.SetPurgeInterval(gorsy_cache.NoPurge).SetDefaultExpiration(gorsy_cache.NoExpiration).Build()
+	defer c.Close()
+	fill := func() {
+		for k := 0; k < 1000; k++ {
+			c.Set(k, k)
+		}
+	}
+	fill()
+
+	c.Resize(8)
+	if n, size := c.Len(), c.Stats().Capacity; n != 8 || size != 8 {
+		t.Errorf("Len() = %d and capacity %d after shrinking, want 8", n, size)
+	}
+	// every shard keeps at least 1 item, so a size under the shards is ignored
+	c.Resize(2)
+	if n, size := c.Len(), c.Stats().Capacity; n != 8 || size != 8 {
+		t.Errorf("Len() = %d and capacity %d after Resize(2), want it ignored", n, size)
+	}
+
+	c.Resize(40)
+	fill()
+	if n, size := c.Len(), c.Stats().Capacity; n != 40 || size != 40 {
+		t.Errorf("Len() = %d and capacity %d after growing, want 40", n, size)
+	}
+}
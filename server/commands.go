@@ -0,0 +1,206 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arianxx/gorsy-cache"
+)
+
+// command is a handler of a redis command.
+// arity is the number of arguments including the command name, a negative arity means at least -arity.
+type command struct {
+	arity int
+	f     func(s *Server, w writer, args [][]byte)
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"ping":    {-1, ping},
+		"echo":    {2, echo},
+		"quit":    {1, quit},
+		"command": {-1, commandInfo},
+		"get":     {2, get},
+		"set":     {-3, set},
+		"del":     {-2, del},
+		"exists":  {-2, exists},
+		"keys":    {2, keys},
+		"dbsize":  {1, dbsize},
+		"flushdb": {-1, flushdb},
+		"ttl":     {2, ttl},
+		"pttl":    {2, pttl},
+	}
+}
+
+// execute executes a command and writes the reply, it reports whether the connection should be closed.
+func (s *Server) execute(w writer, args [][]byte) bool {
+	name := strings.ToLower(string(args[0]))
+	cmd, ok := commands[name]
+	if !ok {
+		w.errorf("ERR unknown command '%s'", args[0])
+		return false
+	}
+	if (cmd.arity > 0 && len(args) != cmd.arity) || len(args) < -cmd.arity {
+		w.errorf("ERR wrong number of arguments for '%s' command", name)
+		return false
+	}
+
+	cmd.f(s, w, args)
+	return name == "quit"
+}
+
+func ping(_ *Server, w writer, args [][]byte) {
+	switch len(args) {
+	case 1:
+		w.simple("PONG")
+	case 2:
+		w.bulk(args[1])
+	default:
+		w.error("ERR wrong number of arguments for 'ping' command")
+	}
+}
+
+func echo(_ *Server, w writer, args [][]byte) {
+	w.bulk(args[1])
+}
+
+func quit(_ *Server, w writer, _ [][]byte) {
+	w.simple("OK")
+}
+
+// commandInfo replies a empty list, which is enough for redis-cli to start.
+func commandInfo(_ *Server, w writer, _ [][]byte) {
+	w.array(0)
+}
+
+// get replies the value of a key, which may be loaded by the loader function of the cache store.
+func get(s *Server, w writer, args [][]byte) {
+	v, err := s.cache.Get(string(args[1]))
+	if err != nil {
+		w.null()
+		return
+	}
+	w.bulk(valueBytes(v))
+}
+
+// set implements `SET key value [EX seconds | PX milliseconds]`.
+// A key set without EX or PX never expires, the same as redis.
+func set(s *Server, w writer, args [][]byte) {
+	var expiration time.Duration = gorsy_cache.NoExpiration
+	for i := 3; i < len(args); i++ {
+		opt := strings.ToLower(string(args[i]))
+		if (opt != "ex" && opt != "px") || i+1 >= len(args) {
+			w.error("ERR syntax error")
+			return
+		}
+		unit := time.Millisecond
+		if opt == "ex" {
+			unit = time.Second
+		}
+		// a time overflowing a time.Duration is rejected, it would wrap to a past or a sentinel expiration
+		n, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+		if err != nil || n <= 0 || n > math.MaxInt64/int64(unit) {
+			w.error("ERR invalid expire time in 'set' command")
+			return
+		}
+		expiration = time.Duration(n) * unit
+		i++
+	}
+
+	value := make([]byte, len(args[2]))
+	copy(value, args[2])
+	s.cache.SetWithExpire(string(args[1]), value, expiration)
+	w.simple("OK")
+}
+
+func del(s *Server, w writer, args [][]byte) {
+	var n int64
+	for _, k := range args[1:] {
+		if s.cache.Remove(string(k)) {
+			n++
+		}
+	}
+	w.integer(n)
+}
+
+func exists(s *Server, w writer, args [][]byte) {
+	var n int64
+	for _, k := range args[1:] {
+		if s.cache.Has(string(k)) {
+			n++
+		}
+	}
+	w.integer(n)
+}
+
+// keys implements `KEYS pattern`, the pattern is matched by globMatch as redis does.
+func keys(s *Server, w writer, args [][]byte) {
+	pattern := string(args[1])
+	matched := make([]string, 0)
+	for _, k := range s.cache.Keys() {
+		key, ok := k.(string)
+		if !ok {
+			key = fmt.Sprint(k)
+		}
+		if globMatch(pattern, key) {
+			matched = append(matched, key)
+		}
+	}
+
+	w.array(len(matched))
+	for _, k := range matched {
+		w.bulk([]byte(k))
+	}
+}
+
+func dbsize(s *Server, w writer, _ [][]byte) {
+	w.integer(int64(s.cache.Len()))
+}
+
+// flushdb implements `FLUSHDB [ASYNC | SYNC]`, both of the modes flush synchronously.
+func flushdb(s *Server, w writer, args [][]byte) {
+	if len(args) > 2 {
+		w.error("ERR syntax error")
+		return
+	}
+	s.cache.Flush()
+	w.simple("OK")
+}
+
+func ttl(s *Server, w writer, args [][]byte) {
+	w.integer(remaining(s, args[1], time.Second))
+}
+
+func pttl(s *Server, w writer, args [][]byte) {
+	w.integer(remaining(s, args[1], time.Millisecond))
+}
+
+// remaining returns the time to live of a key in unit as redis does, -2 if the key is missing,
+// -1 if the key never expires.
+func remaining(s *Server, key []byte, unit time.Duration) int64 {
+	d, ok := s.cache.TTL(string(key))
+	if !ok {
+		return -2
+	}
+	if d == gorsy_cache.NoExpiration {
+		return -1
+	}
+	return int64((d + unit/2) / unit)
+}
+
+// valueBytes converts a stored value to a bulk string, the value set by other than the server is formatted by fmt.
+func valueBytes(v interface{}) []byte {
+	switch v := v.(type) {
+	case []byte:
+		return v
+	case string:
+		return []byte(v)
+	default:
+		return []byte(fmt.Sprint(v))
+	}
+}
@@ -0,0 +1,78 @@
+package server
+
+// globMatch reports whether s matches the glob pattern the way redis does:
+// `*` matches any sequence of bytes including `/`, `?` matches a single byte, `[...]` matches a byte of the
+// set, which may be negated by `^` and contain ranges such as `a-z`, and `\` escapes the next byte.
+// Every pattern is valid, a unterminated set takes the rest of the pattern.
+func globMatch(pattern, s string) bool {
+	p, i := 0, 0
+	// the position of the last star, and of the byte of s it has been matched up to
+	star, next := -1, 0
+	for i < len(s) {
+		if p < len(pattern) {
+			if pattern[p] == '*' {
+				star, next = p, i
+				p++
+				continue
+			}
+			if ok, width := globByte(pattern[p:], s[i]); ok {
+				p += width
+				i++
+				continue
+			}
+		}
+		// let the last star take one more byte and try again
+		if star < 0 {
+			return false
+		}
+		next++
+		p, i = star+1, next
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// globByte matches c against the first token of pattern, which is not a star, and returns the width of the token.
+func globByte(pattern string, c byte) (bool, int) {
+	switch pattern[0] {
+	case '?':
+		return true, 1
+	case '\\':
+		if len(pattern) >= 2 {
+			return pattern[1] == c, 2
+		}
+		return c == '\\', 1
+	case '[':
+		i := 1
+		not := i < len(pattern) && pattern[i] == '^'
+		if not {
+			i++
+		}
+		match := false
+		for i < len(pattern) && pattern[i] != ']' {
+			switch {
+			case pattern[i] == '\\' && i+1 < len(pattern):
+				match = match || pattern[i+1] == c
+				i += 2
+			case i+2 < len(pattern) && pattern[i+1] == '-':
+				lo, hi := pattern[i], pattern[i+2]
+				if lo > hi {
+					lo, hi = hi, lo
+				}
+				match = match || (c >= lo && c <= hi)
+				i += 3
+			default:
+				match = match || pattern[i] == c
+				i++
+			}
+		}
+		if i < len(pattern) {
+			i++
+		}
+		return match != not, i
+	default:
+		return pattern[0] == c, 1
+	}
+}
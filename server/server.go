@@ -0,0 +1,150 @@
+// Package server serves a gorsy cache store over the redis protocol (RESP2),
+// so that the store can be used by redis-cli and the redis clients of any language.
+//
+// The commands supported are GET, SET (with EX and PX), DEL, EXISTS, KEYS, DBSIZE, FLUSHDB, TTL, PTTL,
+// PING, ECHO and QUIT. The keys are strings, and the values are stored as []byte.
+package server
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"sync"
+
+	"github.com/arianxx/gorsy-cache"
+)
+
+// ErrServerClosed is returned by Serve and ListenAndServe after a call to Close.
+var ErrServerClosed = errors.New("server: Server closed")
+
+// Server serves a cache store over the redis protocol.
+type Server struct {
+	cache gorsy_cache.Cache
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	closed    bool
+}
+
+// New returns a server of the cache store.
+func New(c gorsy_cache.Cache) *Server {
+	return &Server{
+		cache:     c,
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[net.Conn]struct{}),
+	}
+}
+
+// ListenAndServe listens on the TCP address addr and serves the connections.
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts the connections on l and serves each of them in a new goroutine.
+// It always returns a non-nil error, ErrServerClosed after a call to Close.
+func (s *Server) Serve(l net.Listener) error {
+	if !s.track(l, nil) {
+		l.Close()
+		return ErrServerClosed
+	}
+	defer s.untrack(l, nil)
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return ErrServerClosed
+			}
+			return err
+		}
+		if !s.track(nil, conn) {
+			conn.Close()
+			return ErrServerClosed
+		}
+		go s.serveConn(conn)
+	}
+}
+
+// Close closes all of the listeners and the connections.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	var err error
+	for l := range s.listeners {
+		if e := l.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	for c := range s.conns {
+		c.Close()
+	}
+	return err
+}
+
+func (s *Server) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.closed
+}
+
+// track records a listener or a connection to be closed by Close, it reports false if the server has been closed.
+func (s *Server) track(l net.Listener, c net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return false
+	}
+	if l != nil {
+		s.listeners[l] = struct{}{}
+	}
+	if c != nil {
+		s.conns[c] = struct{}{}
+	}
+	return true
+}
+
+func (s *Server) untrack(l net.Listener, c net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.listeners, l)
+	delete(s.conns, c)
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer s.untrack(nil, conn)
+	defer conn.Close()
+
+	r := bufio.NewReaderSize(conn, 64<<10)
+	w := writer{bufio.NewWriter(conn)}
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			if err == errProtocol {
+				w.error("ERR Protocol error")
+				w.Flush()
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		quit := s.execute(w, args)
+		// flush when the pipelined commands have been all executed
+		if r.Buffered() == 0 || quit {
+			if err := w.Flush(); err != nil || quit {
+				return
+			}
+		}
+	}
+}
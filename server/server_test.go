@@ -0,0 +1,268 @@
+package server
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	gorsy_cache "github.com/arianxx/gorsy-cache"
+	"github.com/arianxx/gorsy-cache/cachetest"
+)
+
+// client talks to a server over loopback, sending the raw frames and reading the replies.
+type client struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// serve starts a server of a LRU store on a loopback address and returns the address,
+// the time of the store is moved on by the clock returned.
+func serve(t *testing.T) (string, *cachetest.FakeClock) {
+	t.Helper()
+	clock := cachetest.NewFakeClock(time.Unix(0, 0))
+	b, err := gorsy_cache.NewBuilder(gorsy_cache.LRU, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := b.SetDefaultExpiration(gorsy_cache.NoExpiration).SetPurgeInterval(gorsy_cache.NoPurge).SetClock(clock).Build()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := New(c)
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(l) }()
+	t.Cleanup(func() {
+		s.Close()
+		if err := <-done; err != ErrServerClosed {
+			t.Errorf("Serve returned %v, want ErrServerClosed", err)
+		}
+		c.Close()
+	})
+
+	return l.Addr().String(), clock
+}
+
+func dial(t *testing.T, addr string) *client {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	return &client{t: t, conn: conn, r: bufio.NewReader(conn)}
+}
+
+// do sends a command as a array of bulk strings and returns the reply.
+func (c *client) do(args ...string) string {
+	c.t.Helper()
+	var b strings.Builder
+	b.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, a := range args {
+		b.WriteString("$" + strconv.Itoa(len(a)) + "\r\n" + a + "\r\n")
+	}
+	return c.raw(b.String())
+}
+
+// raw sends a frame as it is and returns the reply.
+func (c *client) raw(frame string) string {
+	c.t.Helper()
+	if _, err := io.WriteString(c.conn, frame); err != nil {
+		c.t.Fatal(err)
+	}
+	return c.reply()
+}
+
+// reply reads a reply, rendered as its type byte followed by the content. A bulk string is rendered as `$` and
+// its bytes, and a array as the sorted replies in it joined by spaces in brackets.
+func (c *client) reply() string {
+	c.t.Helper()
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		c.t.Fatalf("read reply: %v", err)
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	switch line[0] {
+	case '$':
+		n, _ := strconv.Atoi(line[1:])
+		if n < 0 {
+			return line
+		}
+		b := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, b); err != nil {
+			c.t.Fatalf("read bulk: %v", err)
+		}
+		return "$" + string(b[:n])
+	case '*':
+		n, _ := strconv.Atoi(line[1:])
+		items := make([]string, n)
+		for i := range items {
+			items[i] = c.reply()
+		}
+		sort.Strings(items)
+		return "[" + strings.Join(items, " ") + "]"
+	default:
+		return line
+	}
+}
+
+// closed reports whether the server has closed the connection.
+func (c *client) closed() bool {
+	_, err := c.r.ReadByte()
+	return err == io.EOF
+}
+
+func expect(t *testing.T, got, want string) {
+	t.Helper()
+	if got != want {
+		t.Errorf("reply %q, want %q", got, want)
+	}
+}
+
+func TestGetSet(t *testing.T) {
+	addr, _ := serve(t)
+	c := dial(t, addr)
+
+	expect(t, c.do("GET", "greeting"), "$-1")
+	expect(t, c.do("SET", "greeting", "hello"), "+OK")
+	expect(t, c.do("GET", "greeting"), "$hello")
+	expect(t, c.do("set", "greeting", "hello world"), "+OK")
+	expect(t, c.do("get", "greeting"), "$hello world")
+	expect(t, c.do("SET", "empty", ""), "+OK")
+	expect(t, c.do("GET", "empty"), "$")
+	expect(t, c.do("DEL", "greeting", "missing"), ":1")
+	expect(t, c.do("GET", "greeting"), "$-1")
+	expect(t, c.do("GET"), "-ERR wrong number of arguments for 'get' command")
+
+	// a inline command is accepted as well
+	expect(t, c.raw("SET inline value\r\n"), "+OK")
+	expect(t, c.raw("GET inline\n"), "$value")
+}
+
+func TestSetExpire(t *testing.T) {
+	addr, clock := serve(t)
+	c := dial(t, addr)
+
+	expect(t, c.do("SET", "forever", "v"), "+OK")
+	expect(t, c.do("SET", "seconds", "v", "EX", "10"), "+OK")
+	expect(t, c.do("SET", "millis", "v", "px", "1500"), "+OK")
+
+	expect(t, c.do("TTL", "forever"), ":-1")
+	expect(t, c.do("TTL", "missing"), ":-2")
+	expect(t, c.do("TTL", "seconds"), ":10")
+	expect(t, c.do("PTTL", "seconds"), ":10000")
+	expect(t, c.do("PTTL", "millis"), ":1500")
+
+	clock.Advance(1501 * time.Millisecond)
+	expect(t, c.do("GET", "millis"), "$-1")
+	expect(t, c.do("PTTL", "millis"), ":-2")
+	expect(t, c.do("PTTL", "seconds"), ":8499")
+	expect(t, c.do("GET", "seconds"), "$v")
+
+	clock.Advance(9 * time.Second)
+	expect(t, c.do("GET", "seconds"), "$-1")
+	expect(t, c.do("GET", "forever"), "$v")
+
+	expect(t, c.do("SET", "k", "v", "EX", "0"), "-ERR invalid expire time in 'set' command")
+	expect(t, c.do("SET", "k", "v", "PX", "soon"), "-ERR invalid expire time in 'set' command")
+	expect(t, c.do("SET", "k", "v", "EX", "9223372037"), "-ERR invalid expire time in 'set' command")
+	expect(t, c.do("SET", "k", "v", "PX", "9223372036855"), "-ERR invalid expire time in 'set' command")
+	expect(t, c.do("SET", "k", "v", "EX", "9223372036854775807"), "-ERR invalid expire time in 'set' command")
+	expect(t, c.do("SET", "k", "v", "EX"), "-ERR syntax error")
+	expect(t, c.do("SET", "k", "v", "KEEPTTL"), "-ERR syntax error")
+	expect(t, c.do("EXISTS", "k"), ":0")
+
+	// the longest times accepted
+	expect(t, c.do("SET", "years", "v", "EX", "9223372036"), "+OK")
+	expect(t, c.do("TTL", "years"), ":9223372036")
+	expect(t, c.do("SET", "years", "v", "PX", "9223372036854"), "+OK")
+	expect(t, c.do("PTTL", "years"), ":9223372036854")
+}
+
+func TestKeys(t *testing.T) {
+	addr, _ := serve(t)
+	c := dial(t, addr)
+
+	for _, k := range []string{"user/1", "user/2", "user/10", "session:1"} {
+		expect(t, c.do("SET", k, "v"), "+OK")
+	}
+	expect(t, c.do("KEYS", "*"), "[$session:1 $user/1 $user/10 $user/2]")
+	expect(t, c.do("KEYS", "user*"), "[$user/1 $user/10 $user/2]")
+	expect(t, c.do("KEYS", "*/1*"), "[$user/1 $user/10]")
+	expect(t, c.do("KEYS", "user/?"), "[$user/1 $user/2]")
+	expect(t, c.do("KEYS", "user/[^1]"), "[$user/2]")
+	expect(t, c.do("KEYS", "[a-t]*"), "[$session:1]")
+	expect(t, c.do("KEYS", "nothing*"), "[]")
+	expect(t, c.do("DBSIZE"), ":4")
+	expect(t, c.do("FLUSHDB"), "+OK")
+	expect(t, c.do("KEYS", "*"), "[]")
+}
+
+func TestGlobMatch(t *testing.T) {
+	for _, tt := range []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"*", "", true},
+		{"*", "a/b/c", true},
+		{"a*c", "a/b/c", true},
+		{"a*c", "a/b/cd", false},
+		{"a**b", "ab", true},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-b]llo", "hbllo", true},
+		{"h[b-a]llo", "hallo", true},
+		{"h[a-b]llo", "hcllo", false},
+		{`h\*llo`, "h*llo", true},
+		{`h\*llo`, "hello", false},
+		{`[\]]`, "]", true},
+		{`a\`, `a\`, true},
+		{"[abc", "b", true},
+		{"[", "[", false},
+		{"", "", true},
+		{"", "a", false},
+		{"*a*a*a*a*a*a*a*b", strings.Repeat("a", 64), false},
+	} {
+		if got := globMatch(tt.pattern, tt.s); got != tt.want {
+			t.Errorf("globMatch(%q, %q) = %v, want %v", tt.pattern, tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestMalformedFrames(t *testing.T) {
+	addr, _ := serve(t)
+	for _, frame := range []string{
+		"*9223372036854775807\r\n",
+		"*1048577\r\n",
+		"*-2\r\n",
+		"*x\r\n",
+		"*1\r\n:1\r\n",
+		"*1\r\n$-1\r\n",
+		"*1\r\n$536870913\r\n",
+		"*1\r\n$3\r\nGETX\r\n",
+	} {
+		c := dial(t, addr)
+		expect(t, c.raw(frame), "-ERR Protocol error")
+		if !c.closed() {
+			t.Errorf("connection is left open after %q", frame)
+		}
+	}
+
+	// the server goes on serving the other connections
+	c := dial(t, addr)
+	expect(t, c.do("PING"), "+PONG")
+	expect(t, c.do("NOSUCH"), "-ERR unknown command 'NOSUCH'")
+	expect(t, c.raw("*0\r\nPING\r\n"), "+PONG")
+}
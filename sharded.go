@@ -0,0 +1,230 @@
+package gorsy_cache
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// NewShardedBuilder receive a registered cache name, a total cache size and a number of shards,
+// return a builder of a cache spreading the keys over the shards by hash.
+// Every shard is a independent cache of the named policy with its own lock, and the size is split evenly among them,
+// as well as the max weight.
+func NewShardedBuilder(name string, size, shards int) (*cacheBuilder, error) {
+	if shards < 1 {
+		return nil, fmt.Errorf("shards must be positive")
+	}
+	if size < shards {
+		return nil, fmt.Errorf("size %d is less than the number of shards %d", size, shards)
+	}
+
+	builder, err := NewBuilder(name, size)
+	if err != nil {
+		return nil, err
+	}
+	builder.shards = shards
+	return builder, nil
+}
+
+// buildShards allocates the shards of a sharded cache by the previous setups.
+func (c *cacheBuilder) buildShards() Cache {
+	sc := &shardedCache{shards: make([]Cache, c.shards)}
+	sc.inherit(c.bc)
+
+	for i := range sc.shards {
+		shard := c.constructor().(Cache)
+		bc := shard.getBaseCache()
+		bc.inherit(c.bc)
+		bc.Name = fmt.Sprintf("%s#%d", c.bc.Name, i)
+		bc.size = c.bc.size / c.shards
+		if i < c.bc.size%c.shards {
+			bc.size++
+		}
+		bc.maxWeight = c.bc.maxWeight / int64(c.shards)
+		if int64(i) < c.bc.maxWeight%int64(c.shards) {
+			bc.maxWeight++
+		}
+		sc.shards[i] = shard
+	}
+
+	return sc
+}
+
+// shardedCache spreads the keys over several independent caches to cut the lock contention.
+// The embedded baseCache only holds the setups, the lock and the statistics of it are unused.
+type shardedCache struct {
+	baseCache
+	shards []Cache
+}
+
+func (c *shardedCache) getBaseCache() *baseCache {
+	return &c.baseCache
+}
+
+func (c *shardedCache) shard(key interface{}) Cache {
+	return c.shards[hashKey(key)%uint64(len(c.shards))]
+}
+
+func (c *shardedCache) Init() {
+	for _, s := range c.shards {
+		s.Init()
+	}
+}
+
+func (c *shardedCache) Get(key interface{}) (interface{}, error) {
+	return c.shard(key).Get(key)
+}
+
+func (c *shardedCache) GetOnlyPresent(key interface{}) (interface{}, bool) {
+	return c.shard(key).GetOnlyPresent(key)
+}
+
+func (c *shardedCache) Set(key, value interface{}) {
+	c.shard(key).Set(key, value)
+}
+
+func (c *shardedCache) SetWithExpire(key, value interface{}, expiration time.Duration) {
+	c.shard(key).SetWithExpire(key, value, expiration)
+}
+
+func (c *shardedCache) Has(key interface{}) bool {
+	return c.shard(key).Has(key)
+}
+
+func (c *shardedCache) TTL(key interface{}) (time.Duration, bool) {
+	return c.shard(key).TTL(key)
+}
+
+func (c *shardedCache) Remove(key interface{}) bool {
+	return c.shard(key).Remove(key)
+}
+
+func (c *shardedCache) Keys() []interface{} {
+	keys := make([]interface{}, 0)
+	for _, s := range c.shards {
+		keys = append(keys, s.Keys()...)
+	}
+
+	return keys
+}
+
+func (c *shardedCache) CleanExpired() int {
+	n := 0
+	for _, s := range c.shards {
+		n += s.CleanExpired()
+	}
+
+	return n
+}
+
+func (c *shardedCache) Flush() {
+	for _, s := range c.shards {
+		s.Flush()
+	}
+}
+
+func (c *shardedCache) drop() {
+	for _, s := range c.shards {
+		s.drop()
+	}
+}
+
+// Resize splits the new size evenly among the shards. A size less than the number of the shards is ignored,
+// as every shard keeps at least 1 item.
+func (c *shardedCache) Resize(n int) {
+	if n < len(c.shards) {
+		return
+	}
+	c.size = n
+	for i, s := range c.shards {
+		size := n / len(c.shards)
+		if i < n%len(c.shards) {
+			size++
+		}
+		s.Resize(size)
+	}
+}
+
+// setLimit spreads the limit over the shards in proportion to their sizes.
+func (c *shardedCache) setLimit(n int) {
+	c.limit = n
+	for _, s := range c.shards {
+		limit := 0
+		if n > 0 && c.size > 0 {
+			limit = max(n*s.getBaseCache().size/c.size, 1)
+		}
+		s.setLimit(limit)
+	}
+}
+
+func (c *shardedCache) Len() int {
+	n := 0
+	for _, s := range c.shards {
+		n += s.Len()
+	}
+
+	return n
+}
+
+func (c *shardedCache) Stats() Stats {
+	var total Stats
+	for _, s := range c.shards {
+		total.add(s.Stats())
+	}
+	total.Name = c.Name
+	total.Policy = c.policy
+	total.Capacity = c.size
+
+	return total
+}
+
+func (c *shardedCache) ResetStats() {
+	for _, s := range c.shards {
+		s.ResetStats()
+	}
+}
+
+func (c *shardedCache) SaveTo(w io.Writer) error {
+	return saveTo(c, w)
+}
+
+func (c *shardedCache) LoadFrom(r io.Reader) error {
+	return loadFrom(c, r)
+}
+
+// snapshot concatenates the snapshots of the shards, so the order of the entries is kept within every shard.
+// The parts of the arc shards are summed up.
+func (c *shardedCache) snapshot() *snapshot {
+	s := &snapshot{Policy: c.policy}
+	for _, shard := range c.shards {
+		ss := shard.snapshot()
+		s.Part += ss.Part
+		s.Entries = append(s.Entries, ss.Entries...)
+	}
+	return s
+}
+
+// restore spreads the entries over the shards by hash, and the part in proportion to the size of the shards.
+func (c *shardedCache) restore(s *snapshot) {
+	subs := make([]*snapshot, len(c.shards))
+	for i, shard := range c.shards {
+		subs[i] = &snapshot{Policy: s.Policy}
+		if c.size > 0 {
+			subs[i].Part = s.Part * shard.getBaseCache().size / c.size
+		}
+	}
+	for _, e := range s.Entries {
+		i := hashKey(e.Key) % uint64(len(c.shards))
+		subs[i].Entries = append(subs[i].Entries, e)
+	}
+
+	for i, shard := range c.shards {
+		shard.restore(subs[i])
+	}
+}
+
+func (c *shardedCache) setRetired(retired bool) {
+	for _, s := range c.shards {
+		s.setRetired(retired)
+	}
+}
@@ -1,6 +1,7 @@
 package gorsy_cache
 
 import (
+	"io"
 	"time"
 )
 
@@ -24,99 +25,133 @@ func (c *simpleCache) getBaseCache() *baseCache {
 }
 
 func (c *simpleCache) Init() {
+	c.resetItems()
 	c.items = make(map[interface{}]*simpleItem, c.size)
 }
 
 func (c *simpleCache) Get(key interface{}) (interface{}, error) {
 	c.RLock()
-	defer c.RUnlock()
+	v, err := c.get(key)
+	c.RUnlock()
+	if err == nil {
+		c.stats.hit()
+		return v, nil
+	}
 
-	return c.get(key, true)
+	c.stats.miss()
+	return c.load(key, c.get, c.Set)
 }
 
 func (c *simpleCache) GetOnlyPresent(key interface{}) (interface{}, bool) {
 	c.RLock()
 	defer c.RUnlock()
 
-	v, err := c.get(key, false)
+	v, err := c.get(key)
 	if err != nil {
+		c.stats.miss()
 		return nil, false
 	} else {
+		c.stats.hit()
 		return v, true
 	}
 }
 
-func (c *simpleCache) get(key interface{}, fromLoader bool) (interface{}, error) {
+func (c *simpleCache) get(key interface{}) (interface{}, error) {
 	item, ok := c.items[key]
-	if ok && !item.isExpired() {
+	if ok && !item.isExpired(c.now()) {
 		return item.value, nil
 	}
 
-	if fromLoader {
-		return c.getFromLoader(key)
-	} else {
-		return nil, &KeyNotFoundError{}
-	}
-}
-
-func (c *simpleCache) getFromLoader(key interface{}) (interface{}, error) {
-	var v interface{}
-	var err error
-	if c.LoaderFunc != nil {
-		v, err = c.LoaderFunc(key)
-		if err != nil {
-			return nil, &KeyNotFoundError{c.Name, key, err}
-		}
-	}
-
-	return v, &KeyNotFoundError{c.Name, key, nil}
+	return nil, &KeyNotFoundError{c.Name, key, nil}
 }
 
 func (c *simpleCache) Set(key, value interface{}) {
 	c.Lock()
-	defer c.Unlock()
+	defer c.unlockAndNotify()
 
 	c.set(key, value, DefaultExpiration)
 }
 
 func (c *simpleCache) set(key, value interface{}, expiration time.Duration) {
+	w := c.weigh(key, value)
+	if c.tooHeavy(w) {
+		c.remove(key, EvictionCapacity)
+		c.reject(key, value)
+		return
+	}
+
 	item, ok := c.items[key]
 	if ok {
+		old := item.value
 		item.value = value
 		item.setExpiration(expiration, &c.baseCache)
+		c.setWeight(&item.baseItem, w)
+		c.evicted(key, old, EvictionReplaced)
+		c.evictOver(0, key)
 		return
 	}
 
-	if len(c.items) == c.size {
-		c.evict(1)
+	if len(c.items) >= c.capacity() {
+		c.evict(len(c.items) - c.capacity() + 1)
 	}
+	c.evictOver(w, nil)
 
-	item = &simpleItem{baseItem{key, value, nil}}
+	item = &simpleItem{newBaseItem(key, value)}
 	item.setExpiration(expiration, &c.baseCache)
+	c.setWeight(&item.baseItem, w)
 	c.items[key] = item
 }
 
 func (c *simpleCache) SetWithExpire(key, value interface{}, expiration time.Duration) {
 	c.Lock()
-	defer c.Unlock()
+	defer c.unlockAndNotify()
 
 	c.set(key, value, expiration)
 }
 
+// evict removes num items, collecting the expired items first and then arbitrary ones.
 func (c *simpleCache) evict(num int) {
-	keys := make([]interface{}, 0)
-	for k, v := range c.items {
-		if v.isExpired() {
-			keys = append(keys, k)
+	now := c.now()
+	for ; num > 0; num-- {
+		item, ok := c.popExpired(now)
+		if !ok {
+			break
 		}
+		c.remove(item.key, EvictionExpired)
+	}
 
-		if len(keys) >= num {
+	for k := range c.items {
+		if num <= 0 {
+			return
+		}
+		c.remove(k, EvictionCapacity)
+		num--
+	}
+}
+
+// evictOver evicts the items over the capacity, and makes room for extra more weight,
+// collecting the expired items first and then any item but keep.
+func (c *simpleCache) evictOver(extra int64, keep interface{}) {
+	over := func() bool {
+		return len(c.items) > c.capacity() || c.overWeight(extra)
+	}
+
+	now := c.now()
+	for over() {
+		item, ok := c.popExpired(now)
+		if !ok {
 			break
 		}
+		c.remove(item.key, EvictionExpired)
 	}
 
-	for _, k := range keys {
-		c.remove(k)
+	for k := range c.items {
+		if !over() {
+			return
+		}
+		if k != keep {
+			c.remove(k, EvictionCapacity)
+		}
 	}
 }
 
@@ -125,28 +160,38 @@ func (c *simpleCache) Has(key interface{}) bool {
 	defer c.RUnlock()
 
 	item, ok := c.items[key]
-	return ok && !item.isExpired()
+	return ok && !item.isExpired(c.now())
+}
+
+func (c *simpleCache) TTL(key interface{}) (time.Duration, bool) {
+	c.RLock()
+	defer c.RUnlock()
+
+	now := c.now()
+	item, ok := c.items[key]
+	if !ok || item.isExpired(now) {
+		return 0, false
+	}
+	return item.ttl(now), true
 }
 
 func (c *simpleCache) Remove(key interface{}) bool {
 	c.Lock()
-	defer c.Unlock()
+	defer c.unlockAndNotify()
 
-	return c.remove(key)
+	return c.remove(key, EvictionExplicit)
 }
 
-func (c *simpleCache) remove(key interface{}) bool {
+func (c *simpleCache) remove(key interface{}, reason EvictionReason) bool {
 	item, ok := c.items[key]
 	if !ok {
 		return false
 	}
 	delete(c.items, key)
+	c.forget(&item.baseItem)
+	c.evicted(key, item.value, reason)
 
-	if c.BeforeEvictedFunc != nil {
-		c.BeforeEvictedFunc(key, item.value)
-	}
-
-	return !item.isExpired()
+	return !item.isExpired(c.now())
 }
 
 func (c *simpleCache) Keys() []interface{} {
@@ -154,8 +199,9 @@ func (c *simpleCache) Keys() []interface{} {
 	defer c.RUnlock()
 
 	keys := make([]interface{}, 0)
+	now := c.now()
 	for k, v := range c.items {
-		if !v.isExpired() {
+		if !v.isExpired(now) {
 			keys = append(keys, k)
 		}
 	}
@@ -164,34 +210,96 @@ func (c *simpleCache) Keys() []interface{} {
 }
 
 func (c *simpleCache) CleanExpired() int {
+	return c.cleanExpired(func(key interface{}) {
+		c.remove(key, EvictionExpired)
+	})
+}
+
+func (c *simpleCache) Flush() {
 	c.Lock()
-	defer c.Unlock()
+	defer c.unlockAndNotify()
 
-	expiredKeys := make([]interface{}, 0)
-	for k, v := range c.items {
-		if v.isExpired() {
-			expiredKeys = append(expiredKeys, k)
-		}
+	for k, item := range c.items {
+		c.evicted(k, item.value, EvictionFlushed)
 	}
+	c.Init()
+}
+
+func (c *simpleCache) drop() {
+	c.Lock()
+	defer c.Unlock()
+
+	c.stats.evicted(EvictionFlushed, len(c.items))
+	c.Init()
+}
 
-	for _, k := range expiredKeys {
-		c.remove(k)
+func (c *simpleCache) Resize(n int) {
+	if n < 1 {
+		return
 	}
+	c.Lock()
+	defer c.unlockAndNotify()
 
-	return len(expiredKeys)
+	c.size = n
+	c.evictOver(0, nil)
 }
 
-func (c *simpleCache) Flush() {
+func (c *simpleCache) setLimit(n int) {
 	c.Lock()
-	defer c.Unlock()
+	defer c.unlockAndNotify()
 
-	c.Init()
+	c.limit = n
+	c.evictOver(0, nil)
 }
 
 func (c *simpleCache) Len() int {
+	c.RLock()
+	defer c.RUnlock()
+
 	return len(c.items)
 }
 
+func (c *simpleCache) Stats() Stats {
+	c.RLock()
+	defer c.RUnlock()
+
+	return c.snapshotStats(len(c.items))
+}
+
+func (c *simpleCache) SaveTo(w io.Writer) error {
+	return saveTo(c, w)
+}
+
+func (c *simpleCache) LoadFrom(r io.Reader) error {
+	return loadFrom(c, r)
+}
+
+func (c *simpleCache) snapshot() *snapshot {
+	c.RLock()
+	defer c.RUnlock()
+
+	s := &snapshot{Policy: c.policy, Entries: make([]snapshotEntry, 0, len(c.items))}
+	now := c.now()
+	for _, item := range c.items {
+		if !item.isExpired(now) {
+			s.Entries = append(s.Entries, newSnapshotEntry(&item.baseItem))
+		}
+	}
+	return s
+}
+
+func (c *simpleCache) restore(s *snapshot) {
+	c.Lock()
+	defer c.unlockAndNotify()
+
+	for _, e := range s.Entries {
+		c.set(e.Key, e.Value, NoExpiration)
+		if item, ok := c.items[e.Key]; ok {
+			item.expireAt(e.Expiration, &c.baseCache)
+		}
+	}
+}
+
 type simpleItem struct {
 	baseItem
 }
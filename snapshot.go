@@ -0,0 +1,155 @@
+package gorsy_cache
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Codec encodes and decodes the snapshots of the cache stores.
+type Codec interface {
+	Encode(w io.Writer, v interface{}) error
+	Decode(r io.Reader, v interface{}) error
+}
+
+// GobCodec is the default codec of the snapshots.
+// As the keys and values are stored as interface{}, their concrete types, except the basic ones,
+// need to be registered by gob.Register before saving or loading a snapshot.
+var GobCodec Codec = gobCodec{}
+
+type gobCodec struct{}
+
+func (gobCodec) Encode(w io.Writer, v interface{}) error {
+	return gob.NewEncoder(w).Encode(v)
+}
+
+func (gobCodec) Decode(r io.Reader, v interface{}) error {
+	return gob.NewDecoder(r).Decode(v)
+}
+
+// The segments of the arc cache an entry may live in.
+const (
+	segmentT1 = iota + 1
+	segmentT2
+)
+
+// snapshot holds the live entries of a cache store, from the first to be evicted to the last.
+type snapshot struct {
+	Policy string
+	// Part is the target size of the t1 of a arc cache.
+	Part    int
+	Entries []snapshotEntry
+}
+
+// snapshotEntry is a live item of a cache store with the access history known by the policy.
+type snapshotEntry struct {
+	Key, Value interface{}
+	// Expiration is the absolute expiration of the item, the zero time means never expire.
+	Expiration time.Time
+	// Freq is the access frequency counted by a lfu cache.
+	Freq int
+	// Segment is the list of a arc cache the item lives in.
+	Segment int
+}
+
+func newSnapshotEntry(item *baseItem) snapshotEntry {
+	e := snapshotEntry{Key: item.key, Value: item.value}
+	if item.expiration != nil {
+		e.Expiration = *item.expiration
+	}
+	return e
+}
+
+// accesses returns the number of the reads of the entry after inserting known by the policy it comes from.
+// A item in the t2 of a arc cache has been accessed at least once.
+func (e *snapshotEntry) accesses() int {
+	if e.Freq == 0 && e.Segment == segmentT2 {
+		return 1
+	}
+	return e.Freq
+}
+
+// expired reports whether the entry has been expired at now.
+func (e *snapshotEntry) expired(now time.Time) bool {
+	return !e.Expiration.IsZero() && e.Expiration.Before(now)
+}
+
+// expireAt sets the absolute expiration of the item, the zero time means never expire.
+func (s *baseItem) expireAt(t time.Time, c *baseCache) {
+	if t.IsZero() {
+		s.expiration = nil
+	} else {
+		s.expiration = &t
+	}
+	c.index(s)
+}
+
+func (c *baseCache) codecOrDefault() Codec {
+	if c.codec == nil {
+		return GobCodec
+	}
+	return c.codec
+}
+
+// saveTo writes the snapshot of c to w by the codec of c.
+func saveTo(c Cache, w io.Writer) error {
+	s := c.snapshot()
+	if err := c.getBaseCache().codecOrDefault().Encode(w, s); err != nil {
+		return fmt.Errorf("save snapshot of `%s`: %s", s.Policy, err.Error())
+	}
+	return nil
+}
+
+// loadFrom restores the snapshot read from r into c by the codec of c.
+// The expired entries are skipped, and the others are stored as the most recently used.
+func loadFrom(c Cache, r io.Reader) error {
+	s := &snapshot{}
+	if err := c.getBaseCache().codecOrDefault().Decode(r, s); err != nil {
+		return fmt.Errorf("load snapshot: %s", err.Error())
+	}
+
+	now := c.getBaseCache().now()
+	live := s.Entries[:0]
+	for _, e := range s.Entries {
+		if !e.expired(now) {
+			live = append(live, e)
+		}
+	}
+	s.Entries = live
+
+	c.restore(s)
+	return nil
+}
+
+// SaveFile writes the snapshot of the cache store to the file at path.
+// The file is replaced atomically, so a crash during saving never leaves a truncated snapshot.
+func SaveFile(c Cache, path string) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if err := c.SaveTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// LoadFile restores the snapshot in the file at path into the cache store.
+func LoadFile(c Cache, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return c.LoadFrom(f)
+}
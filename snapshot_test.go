@@ -0,0 +1,75 @@
+package gorsy_cache_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	gorsy_cache "github.com/arianxx/gorsy-cache"
+)
+
+// TestRestoreEvictionOrder saves a cache with a access history, loads it into a new cache, and fills both alike:
+// the restored cache evicts the keys in the same order as the original one.
+func TestRestoreEvictionOrder(t *testing.T) {
+	const size = 16
+	for _, policy := range []string{gorsy_cache.LRU, gorsy_cache.LFU, gorsy_cache.ARC} {
+		t.Run(policy, func(t *testing.T) {
+			var evicted [2][]int
+			build := func(i int) gorsy_cache.Cache {
+				b, err := gorsy_cache.NewBuilder(policy, size)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return b.
+					SetPurgeInterval(gorsy_cache.NoPurge).
+					SetDefaultExpiration(gorsy_cache.NoExpiration).
+					SetEvictedFunc(func(key, _ interface{}, reason gorsy_cache.EvictionReason) {
+						if reason == gorsy_cache.EvictionCapacity {
+							evicted[i] = append(evicted[i], key.(int))
+						}
+					}).
+					Build()
+			}
+
+			original := build(0)
+			defer original.Close()
+			for k := 0; k < 2*size; k++ {
+				original.Set(k, k)
+				// a history mixing the recency and the frequency: every third key is read again several times,
+				// and the early keys once more at the end.
+				for n := 0; k%3 == 0 && n < k%4+1; n++ {
+					original.Get(k)
+				}
+			}
+			for k := size; k < size+size/2; k += 2 {
+				original.Get(k)
+			}
+			evicted[0] = nil
+
+			var buf bytes.Buffer
+			if err := original.SaveTo(&buf); err != nil {
+				t.Fatal(err)
+			}
+			restored := build(1)
+			defer restored.Close()
+			if err := restored.LoadFrom(&buf); err != nil {
+				t.Fatal(err)
+			}
+
+			for k := 100; k < 100+2*size; k++ {
+				for _, c := range []gorsy_cache.Cache{original, restored} {
+					c.Set(k, k)
+					if k%5 == 0 {
+						c.Get(k)
+					}
+				}
+			}
+			if len(evicted[0]) < size {
+				t.Fatalf("%d keys evicted from the original, want at least %d", len(evicted[0]), size)
+			}
+			if !reflect.DeepEqual(evicted[0], evicted[1]) {
+				t.Errorf("the restored cache evicted\n%v\nthe original\n%v", evicted[1], evicted[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package gorsy_cache
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// EvictionReason tells why a item left the cache store.
+type EvictionReason int
+
+const (
+	// EvictionCapacity means the item was evicted to make room for a new one.
+	EvictionCapacity EvictionReason = iota
+	// EvictionExpired means the item was collected after its expiration.
+	EvictionExpired
+	// EvictionExplicit means the item was removed by Remove.
+	EvictionExplicit
+	// EvictionReplaced means the value was overwritten by a Set of the same key, the key stays in the store.
+	EvictionReplaced
+	// EvictionFlushed means the item was dropped by Flush.
+	EvictionFlushed
+
+	evictionReasons
+)
+
+var evictionReasonNames = [evictionReasons]string{"capacity", "expired", "explicit", "replaced", "flushed"}
+
+func (r EvictionReason) String() string {
+	if r < 0 || r >= evictionReasons {
+		return fmt.Sprintf("EvictionReason(%d)", int(r))
+	}
+	return evictionReasonNames[r]
+}
+
+// Stats is a snapshot of the statistics of a cache store.
+type Stats struct {
+	// Name, Policy and Capacity identify the cache store as it was built.
+	Name     string
+	Policy   string
+	Capacity int
+	// Hits and Misses count the lookups of Get and GetOnlyPresent.
+	Hits   int64
+	Misses int64
+	// LoadSuccesses and LoadFailures count the LoaderFunc calls, LoadTime is the total time spent in them.
+	LoadSuccesses int64
+	LoadFailures  int64
+	LoadTime      time.Duration
+	// The evictions are split by the reason of them.
+	CapacityEvictions int64
+	ExpiredEvictions  int64
+	ExplicitEvictions int64
+	ReplacedEvictions int64
+	FlushedEvictions  int64
+	// Size is the number of items in the store, including the expired ones not collected yet.
+	Size int
+	// Weight is the total weight of the items in the store, see SetWeigher.
+	Weight int64
+}
+
+// HitRatio returns the ratio of hits to lookups, or 0 if there is no lookup.
+func (s Stats) HitRatio() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+// Evictions returns the number of items left the store, the replaced values are not counted since their keys stay.
+func (s Stats) Evictions() int64 {
+	return s.CapacityEvictions + s.ExpiredEvictions + s.ExplicitEvictions + s.FlushedEvictions
+}
+
+// add accumulates the counters and the size of o, which is used to merge the statistics of several caches.
+func (s *Stats) add(o Stats) {
+	s.Hits += o.Hits
+	s.Misses += o.Misses
+	s.LoadSuccesses += o.LoadSuccesses
+	s.LoadFailures += o.LoadFailures
+	s.LoadTime += o.LoadTime
+	s.CapacityEvictions += o.CapacityEvictions
+	s.ExpiredEvictions += o.ExpiredEvictions
+	s.ExplicitEvictions += o.ExplicitEvictions
+	s.ReplacedEvictions += o.ReplacedEvictions
+	s.FlushedEvictions += o.FlushedEvictions
+	s.Size += o.Size
+	s.Weight += o.Weight
+}
+
+// cacheStats holds the counters of a cache store. All of the counters are accessed atomically,
+// since the hits are recorded under the read lock.
+type cacheStats struct {
+	hits          int64
+	misses        int64
+	loadSuccesses int64
+	loadFailures  int64
+	loadTime      int64
+	evictions     [evictionReasons]int64
+}
+
+func (s *cacheStats) hit() {
+	atomic.AddInt64(&s.hits, 1)
+}
+
+func (s *cacheStats) miss() {
+	atomic.AddInt64(&s.misses, 1)
+}
+
+func (s *cacheStats) loaded(d time.Duration, err error) {
+	if err != nil {
+		atomic.AddInt64(&s.loadFailures, 1)
+	} else {
+		atomic.AddInt64(&s.loadSuccesses, 1)
+	}
+	atomic.AddInt64(&s.loadTime, int64(d))
+}
+
+func (s *cacheStats) evicted(reason EvictionReason, n int) {
+	atomic.AddInt64(&s.evictions[reason], int64(n))
+}
+
+func (s *cacheStats) snapshot(size int) Stats {
+	return Stats{
+		Hits:              atomic.LoadInt64(&s.hits),
+		Misses:            atomic.LoadInt64(&s.misses),
+		LoadSuccesses:     atomic.LoadInt64(&s.loadSuccesses),
+		LoadFailures:      atomic.LoadInt64(&s.loadFailures),
+		LoadTime:          time.Duration(atomic.LoadInt64(&s.loadTime)),
+		CapacityEvictions: atomic.LoadInt64(&s.evictions[EvictionCapacity]),
+		ExpiredEvictions:  atomic.LoadInt64(&s.evictions[EvictionExpired]),
+		ExplicitEvictions: atomic.LoadInt64(&s.evictions[EvictionExplicit]),
+		ReplacedEvictions: atomic.LoadInt64(&s.evictions[EvictionReplaced]),
+		FlushedEvictions:  atomic.LoadInt64(&s.evictions[EvictionFlushed]),
+		Size:              size,
+	}
+}
+
+func (s *cacheStats) reset() {
+	atomic.StoreInt64(&s.hits, 0)
+	atomic.StoreInt64(&s.misses, 0)
+	atomic.StoreInt64(&s.loadSuccesses, 0)
+	atomic.StoreInt64(&s.loadFailures, 0)
+	atomic.StoreInt64(&s.loadTime, 0)
+	for i := range s.evictions {
+		atomic.StoreInt64(&s.evictions[i], 0)
+	}
+}
+
+// snapshotStats returns the statistics of the cache store holding size items.
+func (c *baseCache) snapshotStats(size int) Stats {
+	s := c.stats.snapshot(size)
+	s.Name = c.Name
+	s.Policy = c.policy
+	s.Capacity = c.size
+	s.Weight = c.weight
+	return s
+}
+
+// ResetStats zeroes all of the counters of the cache store.
+func (c *baseCache) ResetStats() {
+	c.stats.reset()
+}
@@ -0,0 +1,243 @@
+package gorsy_cache
+
+import "container/list"
+
+func init() {
+	if err := RegisterPolicy(TINYLFU, func() Policy { return &tinyLFU{} }); err != nil {
+		panic(err)
+	}
+}
+
+const (
+	TINYLFU = "tinylfu"
+)
+
+// The segments of a tinyLFU a key may live in.
+const (
+	tinyWindow = iota
+	tinyProbation
+	tinyProtected
+)
+
+// tinyLFU is the W-TinyLFU policy. A new key enters a small lru window, and the key pushed out of the window
+// becomes a candidate of the main space, which is a segmented lru of a probation and a protected part.
+// When the store is full, the candidate only replaces the victim of the probation if it is estimated to be
+// accessed more frequently by a count-min sketch, so the keys accessed only once never pollute the main space.
+type tinyLFU struct {
+	sketch *cmSketch
+	// The fronts of the lists are the most recently used.
+	window, probation, protected *list.List
+	entries                      map[interface{}]*tinyLFUEntry
+
+	windowSize, protectedSize int
+	// candidate is the key just moved from the window to the probation.
+	candidate    interface{}
+	hasCandidate bool
+}
+
+type tinyLFUEntry struct {
+	e       *list.Element
+	segment int
+}
+
+func (p *tinyLFU) Init(size int) {
+	p.setSize(size)
+	p.sketch = newCMSketch(size)
+	p.window = list.New()
+	p.probation = list.New()
+	p.protected = list.New()
+	p.entries = make(map[interface{}]*tinyLFUEntry, size)
+	p.hasCandidate = false
+}
+
+// setSize splits the capacity: the window takes 1% of it, and the protected part 80% of the main space.
+func (p *tinyLFU) setSize(size int) {
+	p.windowSize = max(size/100, 1)
+	p.protectedSize = max(size-p.windowSize, 0) * 8 / 10
+}
+
+// Resize splits the new capacity, and moves the lru keys of the window and the protected part over their new
+// sizes to the probation. The sketch is only replaced when it grows, so the frequencies known are kept.
+func (p *tinyLFU) Resize(size int) {
+	p.setSize(size)
+	if w := newCMSketch(size); len(w.rows[0]) > len(p.sketch.rows[0]) {
+		p.sketch = w
+	}
+
+	for p.window.Len() > p.windowSize {
+		p.move(p.entries[p.window.Back().Value], tinyProbation)
+	}
+	for p.protected.Len() > p.protectedSize {
+		p.move(p.entries[p.protected.Back().Value], tinyProbation)
+	}
+	p.hasCandidate = false
+}
+
+func (p *tinyLFU) list(segment int) *list.List {
+	switch segment {
+	case tinyWindow:
+		return p.window
+	case tinyProbation:
+		return p.probation
+	default:
+		return p.protected
+	}
+}
+
+// move removes the entry from its list and pushes it to the front of the segment.
+func (p *tinyLFU) move(entry *tinyLFUEntry, segment int) {
+	key := p.list(entry.segment).Remove(entry.e)
+	entry.e = p.list(segment).PushFront(key)
+	entry.segment = segment
+}
+
+func (p *tinyLFU) OnAccess(key interface{}) {
+	p.sketch.increment(key)
+
+	entry, ok := p.entries[key]
+	if !ok {
+		return
+	}
+	switch entry.segment {
+	case tinyWindow:
+		p.window.MoveToFront(entry.e)
+	case tinyProbation:
+		p.move(entry, tinyProtected)
+		if p.protected.Len() > p.protectedSize {
+			demoted := p.entries[p.protected.Back().Value]
+			p.move(demoted, tinyProbation)
+		}
+	case tinyProtected:
+		p.protected.MoveToFront(entry.e)
+	}
+}
+
+func (p *tinyLFU) OnInsert(key interface{}) {
+	p.sketch.increment(key)
+	p.entries[key] = &tinyLFUEntry{p.window.PushFront(key), tinyWindow}
+	p.hasCandidate = false
+
+	if p.window.Len() > p.windowSize {
+		k := p.window.Back().Value
+		p.move(p.entries[k], tinyProbation)
+		p.candidate, p.hasCandidate = k, true
+	}
+}
+
+// Victim lets the candidate compete with the lru key of the main space, the less frequent one is evicted.
+func (p *tinyLFU) Victim() (interface{}, bool) {
+	victim, ok := p.lruVictim()
+	if !p.hasCandidate {
+		return victim, ok
+	}
+	if !ok || p.sketch.estimate(p.candidate) <= p.sketch.estimate(victim) {
+		return p.candidate, true
+	}
+	return victim, true
+}
+
+// lruVictim returns the lru key other than the candidate, looking for it in the probation first.
+func (p *tinyLFU) lruVictim() (interface{}, bool) {
+	for _, l := range []*list.List{p.probation, p.protected, p.window} {
+		for e := l.Back(); e != nil; e = e.Prev() {
+			if !p.hasCandidate || e.Value != p.candidate {
+				return e.Value, true
+			}
+		}
+	}
+	return nil, false
+}
+
+func (p *tinyLFU) OnRemove(key interface{}) {
+	entry, ok := p.entries[key]
+	if !ok {
+		return
+	}
+	p.list(entry.segment).Remove(entry.e)
+	delete(p.entries, key)
+	if p.hasCandidate && p.candidate == key {
+		p.hasCandidate = false
+	}
+}
+
+// Order returns the keys of the probation, the window and then the protected part, each from the lru end.
+func (p *tinyLFU) Order() []interface{} {
+	keys := make([]interface{}, 0, len(p.entries))
+	for _, l := range []*list.List{p.probation, p.window, p.protected} {
+		for e := l.Back(); e != nil; e = e.Prev() {
+			keys = append(keys, e.Value)
+		}
+	}
+	return keys
+}
+
+// Frequency estimates the accesses of the key by the sketch, which also counted the insertion.
+func (p *tinyLFU) Frequency(key interface{}) int {
+	return max(p.sketch.estimate(key)-1, 0)
+}
+
+// cmSketch is a count-min sketch of 4 rows of counters saturating at 15, estimating the access frequencies.
+// All of the counters are halved after every sampleSize increments, so the old popularity fades out.
+type cmSketch struct {
+	rows       [cmDepth][]uint8
+	mask       uint64
+	additions  int
+	sampleSize int
+}
+
+const (
+	cmDepth      = 4
+	cmMaxCounter = 15
+)
+
+var cmSeeds = [cmDepth]uint64{0xc3a5c85c97cb3127, 0xb492b66fbe98f273, 0x9ae16a3b2f90404f, 0xcbf29ce484222325}
+
+func newCMSketch(size int) *cmSketch {
+	width := 16
+	for width < size {
+		width <<= 1
+	}
+
+	s := &cmSketch{mask: uint64(width - 1), sampleSize: 10 * width}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+func (s *cmSketch) index(h uint64, row int) uint64 {
+	return mix64(h^cmSeeds[row]) & s.mask
+}
+
+func (s *cmSketch) increment(key interface{}) {
+	h := hashKey(key)
+	for i := range s.rows {
+		if c := &s.rows[i][s.index(h, i)]; *c < cmMaxCounter {
+			*c++
+		}
+	}
+
+	s.additions++
+	if s.additions >= s.sampleSize {
+		s.age()
+	}
+}
+
+func (s *cmSketch) estimate(key interface{}) int {
+	h := hashKey(key)
+	ans := cmMaxCounter
+	for i := range s.rows {
+		ans = min(ans, int(s.rows[i][s.index(h, i)]))
+	}
+	return ans
+}
+
+// age halves all of the counters.
+func (s *cmSketch) age() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	s.additions /= 2
+}
@@ -0,0 +1,82 @@
+package typed
+
+import (
+	"time"
+
+	"github.com/arianxx/gorsy-cache"
+)
+
+// Builder used to build a typed cache. It mirrors the setups of the gorsy_cache builder.
+type Builder[K comparable, V any] struct {
+	policy        string
+	size          int
+	name          string
+	expiration    time.Duration
+	purgeInterval time.Duration
+	loader        LoaderFunc[K, V]
+	beforeEvicted BeforeEvictedFunc[K, V]
+	evicted       EvictedFunc[K, V]
+}
+
+// NewBuilder receive a constant cache name and a cache size, return a typed cache builder.
+// A error will be returned if the specific cache is not registered or the cache implementation is invalid.
+func NewBuilder[K comparable, V any](name string, size int) (*Builder[K, V], error) {
+	if _, err := gorsy_cache.NewBuilder(name, size); err != nil {
+		return nil, err
+	}
+	return &Builder[K, V]{
+		policy:        name,
+		size:          size,
+		expiration:    gorsy_cache.DefaultExpiration,
+		purgeInterval: gorsy_cache.DefaultPurgeInterval,
+	}, nil
+}
+
+// Build a typed cache store by the previous setups.
+func (b *Builder[K, V]) Build() *Cache[K, V] {
+	builder, err := gorsy_cache.NewBuilder(b.policy, b.size)
+	if err != nil {
+		// The policy has been validated by NewBuilder.
+		panic(err)
+	}
+
+	c := builder.
+		SetName(b.name).
+		SetDefaultExpiration(b.expiration).
+		SetPurgeInterval(b.purgeInterval).
+		SetLoaderFunc(Loader(b.loader)).
+		SetBeforeEvictedFunc(BeforeEvicted(b.beforeEvicted)).
+		SetEvictedFunc(Evicted(b.evicted)).
+		Build()
+	return New[K, V](c)
+}
+
+func (b *Builder[K, V]) SetName(n string) *Builder[K, V] {
+	b.name = n
+	return b
+}
+
+func (b *Builder[K, V]) SetDefaultExpiration(t time.Duration) *Builder[K, V] {
+	b.expiration = t
+	return b
+}
+
+func (b *Builder[K, V]) SetLoaderFunc(f LoaderFunc[K, V]) *Builder[K, V] {
+	b.loader = f
+	return b
+}
+
+func (b *Builder[K, V]) SetBeforeEvictedFunc(f BeforeEvictedFunc[K, V]) *Builder[K, V] {
+	b.beforeEvicted = f
+	return b
+}
+
+func (b *Builder[K, V]) SetEvictedFunc(f EvictedFunc[K, V]) *Builder[K, V] {
+	b.evicted = f
+	return b
+}
+
+func (b *Builder[K, V]) SetPurgeInterval(t time.Duration) *Builder[K, V] {
+	b.purgeInterval = t
+	return b
+}
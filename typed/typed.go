@@ -0,0 +1,117 @@
+// Package typed provides a type-safe front end for the gorsy cache stores.
+//
+// A Cache[K, V] wraps any gorsy_cache.Cache, so all of the eviction algorithms registered in the
+// gorsy_cache package can be used without asserting the type of every key and value.
+package typed
+
+import (
+	"time"
+
+	"github.com/arianxx/gorsy-cache"
+)
+
+type (
+	LoaderFunc[K comparable, V any]        func(key K) (V, error)
+	BeforeEvictedFunc[K comparable, V any] func(key K, value V)
+	EvictedFunc[K comparable, V any]       func(key K, value V, reason gorsy_cache.EvictionReason)
+)
+
+// Loader converts a typed loader function to the one accepted by the gorsy_cache builder.
+func Loader[K comparable, V any](f LoaderFunc[K, V]) gorsy_cache.LoaderFunc {
+	if f == nil {
+		return nil
+	}
+	return func(key interface{}) (interface{}, error) {
+		return f(key.(K))
+	}
+}
+
+// BeforeEvicted converts a typed eviction function to the one accepted by the gorsy_cache builder.
+func BeforeEvicted[K comparable, V any](f BeforeEvictedFunc[K, V]) gorsy_cache.BeforeEvictedFunc {
+	if f == nil {
+		return nil
+	}
+	return func(key, value interface{}) {
+		f(key.(K), valueOf[V](value))
+	}
+}
+
+// Evicted converts a typed eviction function with the reason to the one accepted by the gorsy_cache builder.
+func Evicted[K comparable, V any](f EvictedFunc[K, V]) gorsy_cache.EvictedFunc {
+	if f == nil {
+		return nil
+	}
+	return func(key, value interface{}, reason gorsy_cache.EvictionReason) {
+		f(key.(K), valueOf[V](value), reason)
+	}
+}
+
+// Cache is a cache store whose keys are of type K and values are of type V.
+// The untyped store is embedded, so the methods which don't involve keys or values,
+// such as Len, Flush and CleanExpired, are reachable directly.
+type Cache[K comparable, V any] struct {
+	gorsy_cache.Cache
+}
+
+// New wraps a built cache store. All of the writes to c should be performed through the returned Cache,
+// otherwise reading a value of the wrong type will panic.
+func New[K comparable, V any](c gorsy_cache.Cache) *Cache[K, V] {
+	return &Cache[K, V]{c}
+}
+
+func (c *Cache[K, V]) Get(key K) (V, error) {
+	v, err := c.Cache.Get(key)
+	if err != nil {
+		var zero V
+		return zero, err
+	}
+	return valueOf[V](v), nil
+}
+
+func (c *Cache[K, V]) GetOnlyPresent(key K) (V, bool) {
+	v, ok := c.Cache.GetOnlyPresent(key)
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	return valueOf[V](v), true
+}
+
+func (c *Cache[K, V]) Set(key K, value V) {
+	c.Cache.Set(key, value)
+}
+
+func (c *Cache[K, V]) SetWithExpire(key K, value V, duration time.Duration) {
+	c.Cache.SetWithExpire(key, value, duration)
+}
+
+func (c *Cache[K, V]) Has(key K) bool {
+	return c.Cache.Has(key)
+}
+
+func (c *Cache[K, V]) TTL(key K) (time.Duration, bool) {
+	return c.Cache.TTL(key)
+}
+
+func (c *Cache[K, V]) Remove(key K) bool {
+	return c.Cache.Remove(key)
+}
+
+func (c *Cache[K, V]) Keys() []K {
+	keys := c.Cache.Keys()
+	ans := make([]K, 0, len(keys))
+	for _, k := range keys {
+		ans = append(ans, k.(K))
+	}
+	return ans
+}
+
+// valueOf asserts a stored value to V. A nil interface is converted to the zero value of V,
+// since a loader of a pointer or interface type may legitimately return nil.
+func valueOf[V any](v interface{}) V {
+	if v == nil {
+		var zero V
+		return zero
+	}
+	return v.(V)
+}
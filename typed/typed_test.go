@@ -0,0 +1,174 @@
+package typed_test
+
+import (
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/arianxx/gorsy-cache"
+	"github.com/arianxx/gorsy-cache/typed"
+)
+
+type user struct {
+	name string
+}
+
+func build[K comparable, V any](t *testing.T, size int, setup func(b *typed.Builder[K, V])) *typed.Cache[K, V] {
+	t.Helper()
+	b, err := typed.NewBuilder[K, V](gorsy_cache.LRU, size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.SetPurgeInterval(gorsy_cache.NoPurge)
+	if setup != nil {
+		setup(b)
+	}
+	c := b.Build()
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// mustPanic reports whether f panics.
+func mustPanic(t *testing.T, what string, f func()) {
+	t.Helper()
+	defer func() {
+		if recover() == nil {
+			t.Errorf("%s doesn't panic", what)
+		}
+	}()
+	f()
+}
+
+func TestGetSet(t *testing.T) {
+	c := build[string, int](t, 8, nil)
+
+	if _, err := c.Get("missing"); err == nil {
+		t.Error("Get of a missing key succeeded")
+	}
+	if v, ok := c.GetOnlyPresent("missing"); ok || v != 0 {
+		t.Errorf("GetOnlyPresent of a missing key = %v, %v", v, ok)
+	}
+
+	c.Set("a", 1)
+	c.SetWithExpire("b", 2, time.Hour)
+	if v, err := c.Get("a"); err != nil || v != 1 {
+		t.Errorf("Get(a) = %v, %v", v, err)
+	}
+	if v, ok := c.GetOnlyPresent("b"); !ok || v != 2 {
+		t.Errorf("GetOnlyPresent(b) = %v, %v", v, ok)
+	}
+	if ttl, ok := c.TTL("b"); !ok || ttl <= 0 || ttl > time.Hour {
+		t.Errorf("TTL(b) = %s, %v", ttl, ok)
+	}
+	if !c.Has("a") || !c.Remove("a") || c.Has("a") {
+		t.Error("a is not removed")
+	}
+
+	c.Set("c", 3)
+	keys := c.Keys()
+	sort.Strings(keys)
+	if len(keys) != 2 || keys[0] != "b" || keys[1] != "c" {
+		t.Errorf("Keys() = %v, want [b c]", keys)
+	}
+}
+
+func TestLoader(t *testing.T) {
+	errMissing := errors.New("no such user")
+	c := build[int, *user](t, 8, func(b *typed.Builder[int, *user]) {
+		b.SetLoaderFunc(func(id int) (*user, error) {
+			switch id {
+			case 1:
+				return &user{"one"}, nil
+			case 2:
+				// a nil pointer is a valid value
+				return nil, nil
+			default:
+				return nil, errMissing
+			}
+		})
+	})
+
+	if u, err := c.Get(1); err != nil || u.name != "one" {
+		t.Errorf("Get(1) = %v, %v", u, err)
+	}
+	if u, ok := c.GetOnlyPresent(1); !ok || u.name != "one" {
+		t.Errorf("the loaded value isn't stored: %v, %v", u, ok)
+	}
+	if u, err := c.Get(2); err != nil || u != nil {
+		t.Errorf("Get(2) = %v, %v, want a nil user", u, err)
+	}
+	if u, err := c.Get(3); !errors.Is(err, errMissing) || u != nil {
+		t.Errorf("Get(3) = %v, %v, want the error of the loader", u, err)
+	}
+}
+
+func TestEvictedFunc(t *testing.T) {
+	type eviction struct {
+		key    string
+		value  *user
+		reason gorsy_cache.EvictionReason
+	}
+	var evicted []eviction
+	var before []string
+	c := build[string, *user](t, 2, func(b *typed.Builder[string, *user]) {
+		b.SetEvictedFunc(func(key string, value *user, reason gorsy_cache.EvictionReason) {
+			evicted = append(evicted, eviction{key, value, reason})
+		}).SetBeforeEvictedFunc(func(key string, value *user) {
+			before = append(before, key)
+		})
+	})
+
+	c.Set("nil", nil)
+	c.Set("a", &user{"a"})
+	c.Set("b", &user{"b"})
+	c.Remove("a")
+
+	if len(evicted) != 2 || evicted[0] != (eviction{"nil", nil, gorsy_cache.EvictionCapacity}) ||
+		evicted[1].key != "a" || evicted[1].value.name != "a" || evicted[1].reason != gorsy_cache.EvictionExplicit {
+		t.Errorf("evicted %+v", evicted)
+	}
+	if len(before) != 2 || before[0] != "nil" || before[1] != "a" {
+		t.Errorf("BeforeEvictedFunc called for %v", before)
+	}
+}
+
+// TestWrongType writes through the untyped store, the typed reads of a value or a key of another type panic.
+func TestWrongType(t *testing.T) {
+	c := build[string, int](t, 8, nil)
+	c.Cache.Set("text", "not a number")
+
+	mustPanic(t, "Get of a string value", func() { c.Get("text") })
+	mustPanic(t, "GetOnlyPresent of a string value", func() { c.GetOnlyPresent("text") })
+
+	c.Cache.Remove("text")
+	c.Cache.Set(1, 1)
+	mustPanic(t, "Keys with a int key", func() { c.Keys() })
+
+	// the eviction callback of a value of another type panics, which is recovered and reported by the store
+	var reported interface{}
+	b, err := gorsy_cache.NewBuilder(gorsy_cache.LRU, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := b.
+		SetPurgeInterval(gorsy_cache.NoPurge).
+		SetEvictedFunc(typed.Evicted(func(key string, value int, _ gorsy_cache.EvictionReason) {})).
+		SetEvictionPanicFunc(func(_, _ interface{}, _ gorsy_cache.EvictionReason, recovered interface{}) {
+			reported = recovered
+		}).
+		Build()
+	defer u.Close()
+	u.Set("text", "not a number")
+	u.Remove("text")
+	if reported == nil {
+		t.Error("the panic of the typed callback of a string value isn't reported")
+	}
+
+	if f := typed.Loader[string, int](nil); f != nil {
+		t.Error("Loader(nil) isn't nil")
+	}
+	mustPanic(t, "the typed loader of a int key", func() {
+		typed.Loader(func(key string) (int, error) { return 0, nil })(1)
+	})
+}
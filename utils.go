@@ -1,5 +1,10 @@
 package gorsy_cache
 
+import (
+	"math"
+	"reflect"
+)
+
 func min(nums ...int) int {
 	ans := nums[0]
 	for _, v := range nums {
@@ -19,3 +24,123 @@ func max(nums ...int) int {
 	}
 	return ans
 }
+
+// hashKey hashes a key of the cache store, the keys equal by == hash alike. The common key types are hashed
+// without reflection, the others by hashValue.
+func hashKey(key interface{}) uint64 {
+	switch k := key.(type) {
+	case string:
+		return hashString(k)
+	case int:
+		return mix64(uint64(k))
+	case int8:
+		return mix64(uint64(k))
+	case int16:
+		return mix64(uint64(k))
+	case int32:
+		return mix64(uint64(k))
+	case int64:
+		return mix64(uint64(k))
+	case uint:
+		return mix64(uint64(k))
+	case uint8:
+		return mix64(uint64(k))
+	case uint16:
+		return mix64(uint64(k))
+	case uint32:
+		return mix64(uint64(k))
+	case uint64:
+		return mix64(k)
+	case uintptr:
+		return mix64(uint64(k))
+	case bool:
+		if k {
+			return mix64(1)
+		}
+		return mix64(0)
+	case float64:
+		return hashFloat(k)
+	case float32:
+		return hashFloat(float64(k))
+	default:
+		return hashValue(reflect.ValueOf(k))
+	}
+}
+
+// hashValue hashes a comparable value without allocation. The pointers and the channels are hashed by their
+// addresses, and the structs and the arrays field by field, as == compares them.
+func hashValue(v reflect.Value) uint64 {
+	switch v.Kind() {
+	case reflect.String:
+		return hashString(v.String())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return mix64(uint64(v.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return mix64(v.Uint())
+	case reflect.Bool:
+		if v.Bool() {
+			return mix64(1)
+		}
+		return mix64(0)
+	case reflect.Float32, reflect.Float64:
+		return hashFloat(v.Float())
+	case reflect.Complex64, reflect.Complex128:
+		c := v.Complex()
+		return mix64(hashFloat(real(c)) ^ hashFloat(imag(c))*31)
+	case reflect.Ptr, reflect.Chan, reflect.UnsafePointer:
+		return mix64(uint64(v.Pointer()))
+	case reflect.Interface:
+		if v.IsNil() {
+			return 0
+		}
+		return hashValue(v.Elem())
+	case reflect.Array:
+		h := uint64(v.Len())
+		for i := 0; i < v.Len(); i++ {
+			h = mix64(h ^ hashValue(v.Index(i)))
+		}
+		return h
+	case reflect.Struct:
+		h := uint64(v.NumField())
+		t := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			// the blank fields are ignored by ==
+			if t.Field(i).Name == "_" {
+				continue
+			}
+			h = mix64(h ^ hashValue(v.Field(i)))
+		}
+		return h
+	default:
+		// the other kinds can't be a key of a map, the key is rejected by the map of the store
+		return 0
+	}
+}
+
+// hashFloat hashes a float by its bits, -0 is hashed as +0 since they are equal.
+func hashFloat(f float64) uint64 {
+	if f == 0 {
+		f = 0
+	}
+	return mix64(math.Float64bits(f))
+}
+
+// hashString is the 64-bit FNV-1a hash of s.
+func hashString(s string) uint64 {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= 1099511628211
+	}
+	return h
+}
+
+// mix64 is the finalizer of splitmix64, spreading the bits of a integer key.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,79 @@
+package gorsy_cache
+
+import (
+	"math"
+	"testing"
+)
+
+type hashedString string
+
+type hashedKey struct {
+	name  string
+	id    int
+	ratio float64
+	ref   *int
+	any   interface{}
+	_     int
+}
+
+func TestHashKeyEqualKeys(t *testing.T) {
+	n := 1
+	negZero := math.Copysign(0, -1)
+	for _, tt := range []struct {
+		name string
+		a, b interface{}
+	}{
+		{"float zeros", 0.0, negZero},
+		{"float32 zeros", float32(0), float32(negZero)},
+		{"complex zeros", complex(0, 0), complex(negZero, negZero)},
+		{"named string", hashedString("k"), hashedString("k")},
+		{"struct", hashedKey{name: "k", id: 1, ratio: 0, ref: &n, any: 2}, hashedKey{name: "k", id: 1, ratio: negZero, ref: &n, any: 2}},
+		{"array", [2]interface{}{"a", 0.0}, [2]interface{}{"a", negZero}},
+	} {
+		if tt.a != tt.b {
+			t.Fatalf("%s: the keys are not equal", tt.name)
+		}
+		if hashKey(tt.a) != hashKey(tt.b) {
+			t.Errorf("%s: the equal keys %#v and %#v hash differently", tt.name, tt.a, tt.b)
+		}
+	}
+}
+
+func TestHashKeyPointer(t *testing.T) {
+	k := &hashedKey{name: "k"}
+	h := hashKey(k)
+	k.name, k.id = "changed", 2
+	if hashKey(k) != h {
+		t.Error("the hash of a pointer key changes with the value it points to")
+	}
+	if hashKey(&hashedKey{name: "changed", id: 2}) == h {
+		t.Error("the pointers to equal values hash alike")
+	}
+}
+
+func TestShardedPointerKey(t *testing.T) {
+	b, err := NewShardedBuilder(LRU, 64, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := b.SetPurgeInterval(NoPurge).Build()
+	defer c.Close()
+
+	k := &hashedKey{name: "k"}
+	c.Set(k, 1)
+	k.id = 100
+	if _, ok := c.GetOnlyPresent(k); !ok {
+		t.Error("a pointer key is missed after the value it points to changed")
+	}
+	c.Set(k, 2)
+	if n := c.Len(); n != 1 {
+		t.Errorf("Len() = %d, want 1", n)
+	}
+}
+
+func TestHashKeyAllocs(t *testing.T) {
+	var k interface{} = hashedKey{name: "k", id: 1, any: "v"}
+	if n := testing.AllocsPerRun(100, func() { hashKey(k) }); n != 0 {
+		t.Errorf("hashing a struct key allocates %v times", n)
+	}
+}
@@ -0,0 +1,47 @@
+package gorsy_cache
+
+// Weigher returns the weight of a entry, such as the size of the value in bytes, see SetWeigher.
+type Weigher func(key, value interface{}) int64
+
+// weigh returns the weight of a entry, 0 if there is no weigher.
+func (c *baseCache) weigh(key, value interface{}) int64 {
+	if c.weigher == nil {
+		return 0
+	}
+	return c.weigher(key, value)
+}
+
+// tooHeavy reports whether a entry of weight w would never fit in the store.
+func (c *baseCache) tooHeavy(w int64) bool {
+	return c.maxWeight > 0 && w > c.maxWeight
+}
+
+// overWeight reports whether the store would be over its max weight with extra more weight.
+func (c *baseCache) overWeight(extra int64) bool {
+	return c.maxWeight > 0 && c.weight+extra > c.maxWeight
+}
+
+// setWeight changes the weight of a item in the store to w.
+func (c *baseCache) setWeight(item *baseItem, w int64) {
+	c.weight += w - item.weight
+	item.weight = w
+}
+
+// reject drops a entry too heavy to be stored, it is reported as a capacity eviction so that
+// the callbacks can release the value which never entered the store.
+func (c *baseCache) reject(key, value interface{}) {
+	c.evicted(key, value, EvictionCapacity)
+}
+
+// forget releases the bookkeeping of a item leaving the store, it must be called whenever a item is deleted.
+func (c *baseCache) forget(item *baseItem) {
+	c.unindex(item)
+	c.weight -= item.weight
+	item.weight = 0
+}
+
+// resetItems drops the bookkeeping of all of the items, it is called when the store is (re)allocated.
+func (c *baseCache) resetItems() {
+	c.expirations = nil
+	c.weight = 0
+}